@@ -0,0 +1,87 @@
+// Command logagent is a reference log-agent which accepts log-entries from
+// AgentTransport on a Unix domain socket, and produces them to Kafka in the
+// same way as Logger created using log.Init.
+//
+// It is configured using the following environment variables:
+//
+//	LOGAGENT_SOCKET_PATH: Path of Unix domain socket (default: /var/run/logagent/agent.sock)
+//	KAFKA_BROKERS: Comma-separated list of Kafka brokers
+//	KAFKA_LOG_PRODUCER_TOPIC: Topic to which log-entries are produced
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/TerrexTech/go-commonutils/commonutil"
+	"github.com/TerrexTech/go-kafkautils/kafka"
+	tlog "github.com/TerrexTech/go-logtransport/log"
+	"github.com/pkg/errors"
+)
+
+const defaultSocketPath = "/var/run/logagent/agent.sock"
+
+func main() {
+	missingVar, err := commonutil.ValidateEnv(
+		"KAFKA_BROKERS",
+		"KAFKA_LOG_PRODUCER_TOPIC",
+	)
+	if err != nil {
+		err = errors.Wrapf(err, `Env-var "%s" is required, but is not set`, missingVar)
+		log.Fatalln(err)
+	}
+
+	socketPath := os.Getenv("LOGAGENT_SOCKET_PATH")
+	if socketPath == "" {
+		socketPath = defaultSocketPath
+	}
+
+	kt, err := tlog.NewKafkaTransport(
+		&kafka.ProducerConfig{
+			KafkaBrokers: *commonutil.ParseHosts(os.Getenv("KAFKA_BROKERS")),
+		},
+		os.Getenv("KAFKA_LOG_PRODUCER_TOPIC"),
+	)
+	if err != nil {
+		err = errors.Wrap(err, "Error creating Kafka transport")
+		log.Fatalln(err)
+	}
+
+	// Remove socket left over from previous run
+	err = os.Remove(socketPath)
+	if err != nil && !os.IsNotExist(err) {
+		err = errors.Wrap(err, "Error removing existing socket")
+		log.Fatalln(err)
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		err = errors.Wrap(err, "Error listening on socket")
+		log.Fatalln(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		<-sigChan
+		log.Println("Received shutdown signal")
+		cancel()
+	}()
+
+	log.Printf("Log-agent listening on %s", socketPath)
+	err = tlog.ServeAgent(ctx, listener, kt)
+	if err != nil {
+		log.Println(err)
+	}
+
+	err = kt.Close()
+	if err != nil {
+		err = errors.Wrap(err, "Error closing Kafka transport")
+		log.Println(err)
+	}
+	log.Println("--> Closed log-agent")
+}
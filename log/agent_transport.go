@@ -0,0 +1,314 @@
+package log
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/TerrexTech/go-common-models/model"
+	"github.com/pkg/errors"
+)
+
+// maxAgentFrameSize is the maximum size of a frame accepted by ServeAgent.
+const maxAgentFrameSize = 1 << 20
+
+// AgentConfig configures the AgentTransport.
+type AgentConfig struct {
+	// SocketPath is the path of log-agent's Unix domain socket.
+	SocketPath string
+	// QueueSize is the number of entries held in memory while the agent is
+	// unreachable, after which new entries are dropped. Default is 1024.
+	QueueSize int
+	// RetryInterval is the interval between reconnect-attempts while the
+	// agent is unreachable. Default is 1 second.
+	RetryInterval time.Duration
+	// DialTimeout is the timeout for connecting to agent. Default is 5 seconds.
+	DialTimeout time.Duration
+	// WriteTimeout is the timeout for writing an entry. Default is 5 seconds.
+	WriteTimeout time.Duration
+}
+
+// AgentTransport writes log-entries to a local log-agent over a Unix domain
+// socket, such as one shared by the pods on a node. Each entry is framed as a
+// 4-byte big-endian length followed by JSON. Entries are queued in memory and
+// retried while the agent is unreachable. See ServeAgent for the receiving side.
+type AgentTransport struct {
+	config AgentConfig
+	conn   *reconnectingConn
+
+	queue   chan *Record
+	closing chan struct{}
+	done    chan struct{}
+	// closeLock guards queue from being written after Close closes it.
+	closeLock sync.RWMutex
+	closed    bool
+	dropLock  sync.Mutex
+	dropped   int
+}
+
+// agentRecord is the JSON-representation of Record sent to log-agent.
+type agentRecord struct {
+	Entry       model.LogEntry `json:"entry"`
+	Key         string         `json:"key,omitempty"`
+	Time        time.Time      `json:"time"`
+	Caller      string         `json:"caller,omitempty"`
+	Attachments []Attachment   `json:"attachments,omitempty"`
+}
+
+// NewAgentTransport creates a Transport writing to log-agent at config.SocketPath.
+// The connection is established lazily on first write.
+func NewAgentTransport(config AgentConfig) (*AgentTransport, error) {
+	if config.SocketPath == "" {
+		return nil, errors.New("empty SocketPath provided")
+	}
+	if config.QueueSize < 0 {
+		return nil, errors.New("QueueSize cannot be negative")
+	}
+	if config.QueueSize == 0 {
+		config.QueueSize = 1024
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = time.Second
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = 5 * time.Second
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = 5 * time.Second
+	}
+
+	t := &AgentTransport{
+		config: config,
+		conn: &reconnectingConn{
+			network:       "unix",
+			address:       config.SocketPath,
+			dialTimeout:   config.DialTimeout,
+			writeTimeout:  config.WriteTimeout,
+			maxReconnects: 1,
+		},
+		queue:   make(chan *Record, config.QueueSize),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go t.run()
+	return t, nil
+}
+
+// agentFrame encodes the Record as length-prefixed JSON.
+func agentFrame(r *Record) ([]byte, error) {
+	body, err := json.Marshal(agentRecord{
+		Entry:       r.Entry,
+		Key:         r.Key,
+		Time:        r.Time,
+		Caller:      r.Caller,
+		Attachments: r.Attachments,
+	})
+	if err != nil {
+		err = errors.Wrap(err, "Error marshalling agent-record")
+		return nil, err
+	}
+
+	frame := make([]byte, 4, 4+len(body))
+	binary.BigEndian.PutUint32(frame, uint32(len(body)))
+	return append(frame, body...), nil
+}
+
+// readAgentFrame reads a length-prefixed JSON Record.
+func readAgentFrame(r io.Reader) (*Record, error) {
+	var size [4]byte
+	_, err := io.ReadFull(r, size[:])
+	if err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxAgentFrameSize {
+		return nil, errors.Errorf("agent-frame of %d bytes exceeds maximum size", n)
+	}
+
+	body := make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	if err != nil {
+		return nil, err
+	}
+	ar := agentRecord{}
+	err = json.Unmarshal(body, &ar)
+	if err != nil {
+		err = errors.Wrap(err, "Error unmarshalling agent-record")
+		return nil, err
+	}
+	return &Record{
+		Entry:       ar.Entry,
+		Key:         ar.Key,
+		Time:        ar.Time,
+		Caller:      ar.Caller,
+		Attachments: ar.Attachments,
+	}, nil
+}
+
+func (t *AgentTransport) run() {
+	defer close(t.done)
+
+	for r := range t.queue {
+		frame, err := agentFrame(r)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if !t.send(frame) {
+			// Agent is unreachable while closing, so remaining entries are dropped
+			t.dropLock.Lock()
+			t.dropped += len(t.queue) + 1
+			t.dropLock.Unlock()
+			for range t.queue {
+			}
+		}
+
+		t.dropLock.Lock()
+		dropped := t.dropped
+		t.dropped = 0
+		t.dropLock.Unlock()
+		if dropped > 0 {
+			log.Printf(
+				"AgentTransport: dropped %d entries because the agent was unreachable",
+				dropped,
+			)
+		}
+	}
+}
+
+// send writes the frame, retrying every RetryInterval until it succeeds.
+// Returns false if the frame could not be written after Close was called.
+func (t *AgentTransport) send(frame []byte) bool {
+	reported := false
+	for {
+		err := t.conn.write(frame)
+		if err == nil {
+			if reported {
+				log.Println("AgentTransport: reconnected to log-agent")
+			}
+			return true
+		}
+		if !reported {
+			err = errors.Wrapf(
+				err, "Error writing to log-agent at %s, retrying", t.config.SocketPath,
+			)
+			log.Println(err)
+			reported = true
+		}
+
+		select {
+		case <-t.closing:
+			return false
+		case <-time.After(t.config.RetryInterval):
+		}
+	}
+}
+
+// Write queues the Record for sending. The Record is dropped if the queue is full.
+// An error is returned if the Transport is closed.
+func (t *AgentTransport) Write(r *Record) error {
+	t.closeLock.RLock()
+	defer t.closeLock.RUnlock()
+	if t.closed {
+		return errors.New("AgentTransport is closed")
+	}
+	select {
+	case t.queue <- r:
+	default:
+		t.dropLock.Lock()
+		t.dropped++
+		t.dropLock.Unlock()
+	}
+	return nil
+}
+
+// Close sends the queued entries, if the agent is reachable, and closes the connection.
+func (t *AgentTransport) Close() error {
+	t.closeLock.Lock()
+	if t.closed {
+		t.closeLock.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.closing)
+	close(t.queue)
+	t.closeLock.Unlock()
+
+	<-t.done
+	return t.conn.close()
+}
+
+// ServeAgent accepts AgentTransport connections on listener and writes the
+// received Records to provided Transport, such as a KafkaTransport.
+// It returns when the context is closed, after which the listener is closed.
+// The Transport is not closed.
+func ServeAgent(ctx context.Context, listener net.Listener, t Transport) error {
+	if listener == nil {
+		return errors.New("nil listener provided")
+	}
+	if t == nil {
+		return errors.New("nil transport provided")
+	}
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	// Transport.Write is not called concurrently, as required by Transport
+	var (
+		writeLock sync.Mutex
+		wg        sync.WaitGroup
+	)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				wg.Wait()
+				return nil
+			}
+			err = errors.Wrap(err, "Error accepting log-agent connection")
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			connDone := make(chan struct{})
+			defer close(connDone)
+			go func() {
+				select {
+				case <-ctx.Done():
+					conn.Close()
+				case <-connDone:
+				}
+			}()
+
+			for {
+				r, err := readAgentFrame(conn)
+				if err != nil {
+					if err != io.EOF && ctx.Err() == nil {
+						err = errors.Wrap(err, "Error reading from log-agent connection")
+						log.Println(err)
+					}
+					return
+				}
+
+				writeLock.Lock()
+				err = t.Write(r)
+				writeLock.Unlock()
+				if err != nil {
+					err = errors.Wrap(err, "Error forwarding log-entry")
+					log.Println(err)
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,158 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/TerrexTech/go-common-models/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AgentTransport", func() {
+	var (
+		dir        string
+		socketPath string
+		mock       *mockTransport
+		cancel     context.CancelFunc
+		serveDone  chan error
+	)
+
+	// serve starts ServeAgent on the socket
+	serve := func() {
+		listener, err := net.Listen("unix", socketPath)
+		Expect(err).ToNot(HaveOccurred())
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go func() {
+			serveDone <- ServeAgent(ctx, listener, mock)
+		}()
+	}
+
+	record := func(desc string) *Record {
+		return &Record{
+			Entry: model.LogEntry{
+				Action:      "test-action",
+				Description: desc,
+				Level:       "INFO",
+				ServiceName: "testsvc",
+			},
+			Key:    "test-key",
+			Time:   time.Date(2018, 11, 9, 20, 35, 50, 0, time.UTC),
+			Caller: "main.go:12",
+			Attachments: []Attachment{
+				{Index: 0, Type: "string", Value: json.RawMessage(`"test-data"`)},
+			},
+		}
+	}
+
+	descriptions := func() []string {
+		mock.lock.Lock()
+		defer mock.lock.Unlock()
+		descs := []string{}
+		for _, r := range mock.records {
+			descs = append(descs, r.Entry.Description)
+		}
+		return descs
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "agent")
+		Expect(err).ToNot(HaveOccurred())
+		socketPath = filepath.Join(dir, "agent.sock")
+		mock = &mockTransport{}
+		cancel = nil
+		serveDone = make(chan error, 1)
+	})
+
+	AfterEach(func() {
+		if cancel != nil {
+			cancel()
+			Eventually(serveDone).Should(Receive(BeNil()))
+		}
+		os.RemoveAll(dir)
+	})
+
+	It("should forward entries to agent's transport", func() {
+		serve()
+		t, err := NewAgentTransport(AgentConfig{
+			SocketPath: socketPath,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(t.Write(record("first"))).To(Succeed())
+		Expect(t.Write(record("second"))).To(Succeed())
+		Expect(t.Close()).To(Succeed())
+
+		Eventually(descriptions).Should(Equal([]string{"first", "second"}))
+		mock.lock.Lock()
+		Expect(mock.records[0]).To(Equal(record("first")))
+		mock.lock.Unlock()
+	})
+
+	It("should queue entries until the agent is reachable", func() {
+		t, err := NewAgentTransport(AgentConfig{
+			SocketPath:    socketPath,
+			RetryInterval: 10 * time.Millisecond,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(t.Write(record("first"))).To(Succeed())
+		Expect(t.Write(record("second"))).To(Succeed())
+		time.Sleep(50 * time.Millisecond)
+
+		serve()
+		Eventually(descriptions).Should(Equal([]string{"first", "second"}))
+		Expect(t.Close()).To(Succeed())
+	})
+
+	It("should drop entries when the queue is full", func() {
+		t, err := NewAgentTransport(AgentConfig{
+			SocketPath:    socketPath,
+			QueueSize:     1,
+			RetryInterval: time.Hour,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		for i := 0; i < 5; i++ {
+			Expect(t.Write(record("test"))).To(Succeed())
+		}
+		t.dropLock.Lock()
+		Expect(t.dropped).To(BeNumerically(">=", 3))
+		t.dropLock.Unlock()
+		Expect(t.Close()).To(Succeed())
+	})
+
+	It("should return error when writing after Close", func() {
+		t, err := NewAgentTransport(AgentConfig{
+			SocketPath:    socketPath,
+			RetryInterval: time.Hour,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(t.Close()).To(Succeed())
+		Expect(t.Write(record("test"))).To(HaveOccurred())
+		Expect(t.Close()).To(Succeed())
+	})
+
+	It("should reject frames exceeding maximum size", func() {
+		frame := make([]byte, 4)
+		binary.BigEndian.PutUint32(frame, maxAgentFrameSize+1)
+		_, err := readAgentFrame(bytes.NewReader(frame))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return error if SocketPath is empty", func() {
+		_, err := NewAgentTransport(AgentConfig{})
+		Expect(err).To(HaveOccurred())
+	})
+})
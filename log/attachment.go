@@ -0,0 +1,161 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/TerrexTech/go-common-models/model"
+	"github.com/pkg/errors"
+)
+
+// Attachment is a data argument provided to a log-level function, which is
+// attached to the Record as JSON when the level is DEBUG.
+type Attachment struct {
+	// Index is the position of data in the arguments.
+	Index int `json:"index"`
+	// Type is the Go type of data, such as "model.Event".
+	// Pointers are dereferenced, so this is never a pointer-type.
+	Type string `json:"type"`
+	// Value is the data as JSON. The formatters registered using
+	// RegisterFormatter, and LogValue methods, are applied recursively,
+	// followed by redaction and the Logger's FormatLimits.
+	Value json.RawMessage `json:"value"`
+	// Truncated is true if any part of Value was trimmed as per FormatLimits.
+	Truncated bool `json:"truncated,omitempty"`
+	// Redacted is true if any part of Value was masked, see RedactionRule.
+	Redacted bool `json:"redacted,omitempty"`
+	// Length is the original length if data is an array or slice.
+	Length int `json:"length,omitempty"`
+	// Error is set if data could not be marshalled, in which case
+	// Value is the data formatted as a string.
+	Error string `json:"error,omitempty"`
+}
+
+// newAttachments creates the Attachments for data arguments. Attachments
+// exceeding limits.MaxBytes, in total, are replaced by a truncation marker.
+func newAttachments(
+	limits FormatLimits, r *redactor, data ...interface{},
+) []Attachment {
+	if len(data) == 0 {
+		return nil
+	}
+	attachments := make([]Attachment, len(data))
+	remaining := limits.MaxBytes
+	for i, d := range data {
+		a := newAttachment(i, d, limits, r)
+		if limits.MaxBytes > 0 {
+			if len(a.Value) > remaining {
+				marker := fmt.Sprintf(
+					"...[%d bytes exceed max bytes per entry]", len(a.Value),
+				)
+				a.Value, _ = json.Marshal(marker)
+				a.Truncated = true
+			}
+			remaining -= len(a.Value)
+			if remaining < 0 {
+				remaining = 0
+			}
+		}
+		attachments[i] = a
+	}
+	return attachments
+}
+
+func newAttachment(
+	index int, d interface{}, limits FormatLimits, r *redactor,
+) Attachment {
+	a := Attachment{
+		Index: index,
+		Type:  "nil",
+		Value: json.RawMessage("null"),
+	}
+	if d == nil {
+		return a
+	}
+	dataType := reflect.TypeOf(d)
+	if dataType.Kind() == reflect.Ptr {
+		dataType = dataType.Elem()
+	}
+	a.Type = dataType.String()
+
+	d = derefData(d)
+	if d == nil {
+		return a
+	}
+	v := reflect.ValueOf(d)
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		a.Length = v.Len()
+	}
+
+	value, err := json.Marshal(formatValue(d, 0, &a.Redacted))
+	marshalled := err == nil
+	if err != nil {
+		a.Error = err.Error()
+		placeholder := fmt.Sprintf("%+v", d)
+		// Tagged fields cannot be masked without JSON, so the value is omitted
+		if hasRedactTags(v.Type()) {
+			placeholder = fmt.Sprintf("[unserializable %s]", a.Type)
+			a.Redacted = true
+		}
+		value, _ = json.Marshal(placeholder)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	// Keep the numbers as is, instead of converting to float64
+	decoder.UseNumber()
+	var generic interface{}
+	err = decoder.Decode(&generic)
+	if err != nil {
+		a.Error = errors.Wrap(err, "Error decoding attachment").Error()
+		a.Value = value
+		return a
+	}
+
+	// Redaction is applied first, so the limits cannot split sensitive values
+	if marshalled {
+		generic = redactTagged(v, generic, &a.Redacted)
+	}
+	generic = r.redactValue(generic, &a.Redacted)
+	generic = limits.limit(generic, 1, &a.Truncated)
+
+	a.Value = value
+	// Value is only re-marshalled if changed, to keep the order of struct-fields
+	if a.Redacted || a.Truncated {
+		a.Value, err = json.Marshal(generic)
+		if err != nil {
+			a.Error = err.Error()
+			a.Value = json.RawMessage("null")
+		}
+	}
+	return a
+}
+
+// entryJSON is the JSON of a Record sent by Transports, which is the
+// log-entry with its attachments.
+type entryJSON struct {
+	model.LogEntry
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+func newEntryJSON(r *Record) entryJSON {
+	return entryJSON{
+		LogEntry:    r.Entry,
+		Attachments: r.Attachments,
+	}
+}
+
+// attachmentsJSON returns the attachments as a JSON array, or an empty string
+// if there are none, for Transports whose fields can only be strings.
+func attachmentsJSON(attachments []Attachment) string {
+	if len(attachments) == 0 {
+		return ""
+	}
+	ml, err := json.Marshal(attachments)
+	if err != nil {
+		// Values are valid JSON, so this isn't expected
+		return fmt.Sprintf(`[{"error":%q}]`, err.Error())
+	}
+	return string(ml)
+}
@@ -0,0 +1,68 @@
+package log
+
+import (
+	"encoding/json"
+
+	"github.com/TerrexTech/go-common-models/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Attachment", func() {
+	It("should attach data with type and JSON value", func() {
+		var nilEvent *model.Event
+		attachments := newAttachments(
+			DefaultFormatLimits,
+			nil,
+			&model.EventMeta{AggregateID: 2, AggregateVersion: 8},
+			"test-data",
+			nil,
+			nilEvent,
+		)
+		Expect(attachments).To(Equal([]Attachment{
+			{
+				Index: 0,
+				Type:  "model.EventMeta",
+				Value: json.RawMessage(`{"aggregateID":2,"aggregateVersion":8}`),
+			},
+			{Index: 1, Type: "string", Value: json.RawMessage(`"test-data"`)},
+			{Index: 2, Type: "nil", Value: json.RawMessage("null")},
+			{Index: 3, Type: "model.Event", Value: json.RawMessage("null")},
+		}))
+		Expect(newAttachments(DefaultFormatLimits, nil)).To(BeNil())
+	})
+
+	It("should trim arrays exceeding array-threshold", func() {
+		limits := FormatLimits{ArrayThreshold: 2}
+		a := newAttachment(0, [][]int{{1, 2, 3}, {4}, {5}}, limits, nil)
+		Expect(a.Type).To(Equal("[][]int"))
+		Expect(string(a.Value)).To(Equal(
+			`[[1,2,"...[1 more elements]"],[4],"...[1 more elements]"]`,
+		))
+		Expect(a.Truncated).To(BeTrue())
+		Expect(a.Length).To(Equal(3))
+
+		a = newAttachment(0, []int{1, 2}, limits, nil)
+		Expect(string(a.Value)).To(Equal("[1,2]"))
+		Expect(a.Truncated).To(BeFalse())
+	})
+
+	It("should parse nested Data of Common-Models", func() {
+		a := newAttachment(0, model.Document{
+			Data: []byte(`{"name":"test"}`),
+		}, DefaultFormatLimits, nil)
+		value := map[string]interface{}{}
+		Expect(json.Unmarshal(a.Value, &value)).To(Succeed())
+		Expect(value["data"]).To(Equal(map[string]interface{}{"name": "test"}))
+	})
+
+	It("should format data as string if it cannot be marshalled", func() {
+		a := newAttachment(1, func() {}, DefaultFormatLimits, nil)
+		Expect(a.Type).To(Equal("func()"))
+		Expect(a.Error).ToNot(BeEmpty())
+
+		value := ""
+		Expect(json.Unmarshal(a.Value, &value)).To(Succeed())
+		Expect(value).ToNot(BeEmpty())
+	})
+})
@@ -0,0 +1,158 @@
+package log
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// batcher accumulates Records and flushes them in batches, either when
+// the batch is full or when the flush-interval passes.
+type batcher struct {
+	size     int
+	interval time.Duration
+	flush    func(records []*Record)
+
+	lock    sync.Mutex
+	records []*Record
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newBatcher(size int, interval time.Duration, flush func([]*Record)) *batcher {
+	b := &batcher{
+		size:     size,
+		interval: interval,
+		flush:    flush,
+		records:  make([]*Record, 0, size),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+func (b *batcher) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			b.lock.Lock()
+			records := b.take()
+			b.lock.Unlock()
+			if len(records) > 0 {
+				b.flush(records)
+			}
+		}
+	}
+}
+
+// take returns the current batch and starts a new one. Lock must be held.
+func (b *batcher) take() []*Record {
+	records := b.records
+	b.records = make([]*Record, 0, b.size)
+	return records
+}
+
+// add adds the Record to batch, flushing the batch if full.
+func (b *batcher) add(r *Record) {
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return
+	}
+	b.records = append(b.records, r)
+	var records []*Record
+	if len(b.records) >= b.size {
+		records = b.take()
+	}
+	b.lock.Unlock()
+
+	if len(records) > 0 {
+		b.flush(records)
+	}
+}
+
+// close stops the flush-interval and flushes remaining Records.
+func (b *batcher) close() {
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return
+	}
+	b.closed = true
+	records := b.take()
+	b.lock.Unlock()
+
+	close(b.stop)
+	<-b.done
+	if len(records) > 0 {
+		b.flush(records)
+	}
+}
+
+// batchConfig holds batching and concurrency settings shared by batching transports.
+type batchConfig struct {
+	size        int
+	interval    time.Duration
+	concurrency int
+}
+
+// batchSender sends batches of Records concurrently, bounded by the
+// configured concurrency, using the provided send function.
+type batchSender struct {
+	name    string
+	send    func(records []*Record) error
+	batcher *batcher
+	sem     chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newBatchSender(
+	name string, config batchConfig, send func([]*Record) error,
+) *batchSender {
+	s := &batchSender{
+		name: name,
+		send: send,
+		sem:  make(chan struct{}, config.concurrency),
+	}
+	s.batcher = newBatcher(config.size, config.interval, s.flush)
+	return s
+}
+
+// flush sends the batch asynchronously, blocking if too many requests are in flight.
+func (s *batchSender) flush(records []*Record) {
+	s.sem <- struct{}{}
+	s.wg.Add(1)
+	go func() {
+		defer func() {
+			<-s.sem
+			s.wg.Done()
+		}()
+		err := s.send(records)
+		if err != nil {
+			err = errors.Wrapf(
+				err, "Error sending batch of %d entries to %s", len(records), s.name,
+			)
+			log.Println(err)
+		}
+	}()
+}
+
+func (s *batchSender) add(r *Record) {
+	s.batcher.add(r)
+}
+
+// close sends remaining Records and waits for requests in flight.
+func (s *batchSender) close() {
+	s.batcher.close()
+	s.wg.Wait()
+}
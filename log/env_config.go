@@ -0,0 +1,284 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/TerrexTech/go-commonutils/commonutil"
+	"github.com/TerrexTech/go-kafkautils/kafka"
+	"github.com/pkg/errors"
+)
+
+// Environment-variables read by InitFromEnv, in addition to
+// LogLevelEnvVar and LogSinkEnvVar.
+const (
+	// KafkaBrokersEnvVar is the comma-separated list of Kafka brokers.
+	// Required for "kafka" sink.
+	KafkaBrokersEnvVar = "KAFKA_BROKERS"
+	// KafkaTopicEnvVar is the topic to which log-entries are produced.
+	// Required for "kafka" sink.
+	KafkaTopicEnvVar = "KAFKA_LOG_PRODUCER_TOPIC"
+	// BufferSizeEnvVar is the number of entries queued for Kafka before new
+	// entries are dropped. Default is DefaultBufferSize.
+	BufferSizeEnvVar = "LOG_BUFFER_SIZE"
+	// OutputEnvVar is where the log-entries are written besides the sink.
+	// Valid values are "stdout", which is the default, "stderr" and "none".
+	OutputEnvVar = "LOG_OUTPUT"
+	// FormatEnvVar is the format of logs written to output, see NewFormatter.
+	// Default is "debug".
+	FormatEnvVar = "LOG_FORMAT"
+
+	// KafkaTLSEnabledEnvVar enables TLS for connecting to brokers, if "true".
+	KafkaTLSEnabledEnvVar = "KAFKA_TLS_ENABLED"
+	// KafkaTLSCAFileEnvVar is the PEM file of CA-certificates for verifying
+	// brokers. System's CAs are used if not set.
+	KafkaTLSCAFileEnvVar = "KAFKA_TLS_CA_FILE"
+	// KafkaTLSCertFileEnvVar is the PEM file of client-certificate, for
+	// brokers requiring client-authentication. Requires KafkaTLSKeyFileEnvVar.
+	KafkaTLSCertFileEnvVar = "KAFKA_TLS_CERT_FILE"
+	// KafkaTLSKeyFileEnvVar is the PEM file of client-certificate's private key.
+	KafkaTLSKeyFileEnvVar = "KAFKA_TLS_KEY_FILE"
+	// KafkaTLSSkipVerifyEnvVar disables verification of brokers' certificates,
+	// if "true". This should only be used for testing.
+	KafkaTLSSkipVerifyEnvVar = "KAFKA_TLS_INSECURE_SKIP_VERIFY"
+	// KafkaTLSServerNameEnvVar is used to verify brokers' certificates, if it
+	// differs from the brokers' addresses.
+	KafkaTLSServerNameEnvVar = "KAFKA_TLS_SERVER_NAME"
+
+	// KafkaSASLMechanismEnvVar enables SASL authentication with brokers.
+	// Valid values are "PLAIN", "SCRAM-SHA-256" and "SCRAM-SHA-512".
+	KafkaSASLMechanismEnvVar = "KAFKA_SASL_MECHANISM"
+	// KafkaSASLUsernameEnvVar is the SASL username. Required if SASL is enabled.
+	KafkaSASLUsernameEnvVar = "KAFKA_SASL_USERNAME"
+	// KafkaSASLPasswordEnvVar is the SASL password. This or
+	// KafkaSASLPasswordFileEnvVar is required if SASL is enabled.
+	KafkaSASLPasswordEnvVar = "KAFKA_SASL_PASSWORD"
+	// KafkaSASLPasswordFileEnvVar is the file containing the SASL password,
+	// such as a mounted secret.
+	KafkaSASLPasswordFileEnvVar = "KAFKA_SASL_PASSWORD_FILE"
+)
+
+// EnvVarError describes a missing or malformed environment-variable.
+type EnvVarError struct {
+	Name   string
+	Reason string
+}
+
+// EnvError is returned by InitFromEnv, and lists every missing or
+// malformed environment-variable.
+type EnvError struct {
+	Vars []EnvVarError
+}
+
+func (e *EnvError) Error() string {
+	msgs := make([]string, len(e.Vars))
+	for i, v := range e.Vars {
+		msgs[i] = v.Name + ": " + v.Reason
+	}
+	return "invalid environment-variables: " + strings.Join(msgs, "; ")
+}
+
+// envReader reads environment-variables, collecting the errors.
+type envReader struct {
+	errs []EnvVarError
+}
+
+func (r *envReader) fail(name string, format string, args ...interface{}) {
+	r.errs = append(r.errs, EnvVarError{
+		Name:   name,
+		Reason: fmt.Sprintf(format, args...),
+	})
+}
+
+func (r *envReader) required(name string) string {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		r.fail(name, "required, but is not set")
+	}
+	return v
+}
+
+// oneOf reads a variable which must be one of valid values, returning
+// defaultValue if it is not set.
+func (r *envReader) oneOf(name string, defaultValue string, valid ...string) string {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return defaultValue
+	}
+	for _, value := range valid {
+		if v == value {
+			return v
+		}
+	}
+	r.fail(name, "invalid value %q, valid values are: %s", v, strings.Join(valid, ", "))
+	return defaultValue
+}
+
+func (r *envReader) bool(name string) bool {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		r.fail(name, "invalid boolean %q", v)
+	}
+	return b
+}
+
+func (r *envReader) positiveInt(name string) int {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		r.fail(name, "must be a positive integer, got %q", v)
+		return 0
+	}
+	return n
+}
+
+// InitFromEnv creates a new Logger configured using environment-variables.
+// See LogLevelEnvVar, LogSinkEnvVar and the constants ending with "EnvVar"
+// for the variables read. All variables are validated before creating the
+// Logger, and an *EnvError listing every missing or malformed variable is
+// returned if any are invalid.
+func InitFromEnv(
+	ctx context.Context,
+	// svcName is the default ServiceName to be used
+	// when ServiceName is not provided in LogEntry model.
+	svcName string,
+) (Logger, error) {
+	if svcName == "" {
+		return nil, errors.New("empty svcName provided")
+	}
+
+	r := &envReader{}
+	r.oneOf(LogLevelEnvVar, "", "DEBUG", "INFO", "ERROR", "NONE")
+	sink := r.oneOf(LogSinkEnvVar, "kafka", "kafka", "stdout")
+	output := r.oneOf(OutputEnvVar, "stdout", "stdout", "stderr", "none")
+	format := r.oneOf(
+		FormatEnvVar, "debug",
+		"debug", "description", "text", "json", "logfmt", "color",
+	)
+	bufferSize := r.positiveInt(BufferSizeEnvVar)
+
+	var (
+		prodConfig *kafka.ProducerConfig
+		topic      string
+	)
+	if sink == "kafka" {
+		brokers := r.required(KafkaBrokersEnvVar)
+		topic = r.required(KafkaTopicEnvVar)
+		prodConfig = &kafka.ProducerConfig{
+			KafkaBrokers: *commonutil.ParseHosts(brokers),
+			SaramaConfig: newSaramaConfig(),
+		}
+		r.kafkaSecurity(prodConfig.SaramaConfig)
+	}
+
+	if len(r.errs) > 0 {
+		return nil, &EnvError{Vars: r.errs}
+	}
+
+	var (
+		l   Logger
+		err error
+	)
+	if sink == "stdout" {
+		l, err = InitOutput(ctx, svcName, nil)
+	} else {
+		var kt *KafkaTransport
+		kt, err = NewKafkaTransport(prodConfig, topic)
+		if err != nil {
+			return nil, err
+		}
+		l, err = InitTransport(ctx, svcName, kt, TransportConfig{
+			Name:       "kafka",
+			BufferSize: bufferSize,
+		})
+		if err != nil {
+			kt.Close()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	formatter, _ := NewFormatter(format)
+	l.SetFormatter(formatter)
+	switch output {
+	case "stderr":
+		l.SetOutput(os.Stderr)
+	case "none":
+		l.DisableOutput()
+	}
+	return l, nil
+}
+
+// newSaramaConfig creates the producer-config used by kafka.NewProducer
+// when no SaramaConfig is provided.
+func newSaramaConfig() *sarama.Config {
+	config := sarama.NewConfig()
+	config.Producer.Return.Errors = true
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Compression = sarama.CompressionNone
+	config.Version = sarama.V2_0_0_0
+	return config
+}
+
+// kafkaSecurity reads the TLS and SASL settings into config.
+func (r *envReader) kafkaSecurity(config *sarama.Config) {
+	if r.bool(KafkaTLSEnabledEnvVar) {
+		kafkaTLS := KafkaTLS{
+			CAFile:             os.Getenv(KafkaTLSCAFileEnvVar),
+			CertFile:           os.Getenv(KafkaTLSCertFileEnvVar),
+			KeyFile:            os.Getenv(KafkaTLSKeyFileEnvVar),
+			ServerName:         os.Getenv(KafkaTLSServerNameEnvVar),
+			InsecureSkipVerify: r.bool(KafkaTLSSkipVerifyEnvVar),
+		}
+		switch {
+		case kafkaTLS.CertFile != "" && kafkaTLS.KeyFile == "":
+			r.fail(KafkaTLSKeyFileEnvVar, "required when %s is set", KafkaTLSCertFileEnvVar)
+		case kafkaTLS.KeyFile != "" && kafkaTLS.CertFile == "":
+			r.fail(KafkaTLSCertFileEnvVar, "required when %s is set", KafkaTLSKeyFileEnvVar)
+		default:
+			err := kafkaTLS.apply(config)
+			if err != nil {
+				r.fail(KafkaTLSEnabledEnvVar, "%s", err)
+			}
+		}
+	}
+
+	mechanism := r.oneOf(
+		KafkaSASLMechanismEnvVar, "",
+		sarama.SASLTypePlaintext, sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512,
+	)
+	if mechanism != "" {
+		sasl := KafkaSASL{
+			Mechanism:    mechanism,
+			Username:     r.required(KafkaSASLUsernameEnvVar),
+			Password:     os.Getenv(KafkaSASLPasswordEnvVar),
+			PasswordFile: os.Getenv(KafkaSASLPasswordFileEnvVar),
+		}
+		switch {
+		case sasl.Password == "" && sasl.PasswordFile == "":
+			r.fail(KafkaSASLPasswordEnvVar, "required, but is not set")
+		case sasl.Password != "" && sasl.PasswordFile != "":
+			r.fail(
+				KafkaSASLPasswordFileEnvVar,
+				"cannot be set with %s", KafkaSASLPasswordEnvVar,
+			)
+		case sasl.Username != "":
+			err := sasl.apply(config)
+			if err != nil {
+				r.fail(KafkaSASLPasswordFileEnvVar, "%s", err)
+			}
+		}
+	}
+}
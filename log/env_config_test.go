@@ -0,0 +1,169 @@
+package log
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Shopify/sarama"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("InitFromEnv", func() {
+	envVars := []string{
+		LogLevelEnvVar,
+		LogSinkEnvVar,
+		KafkaBrokersEnvVar,
+		KafkaTopicEnvVar,
+		BufferSizeEnvVar,
+		OutputEnvVar,
+		FormatEnvVar,
+		KafkaTLSEnabledEnvVar,
+		KafkaTLSCAFileEnvVar,
+		KafkaTLSCertFileEnvVar,
+		KafkaTLSKeyFileEnvVar,
+		KafkaTLSSkipVerifyEnvVar,
+		KafkaTLSServerNameEnvVar,
+		KafkaSASLMechanismEnvVar,
+		KafkaSASLUsernameEnvVar,
+		KafkaSASLPasswordEnvVar,
+		KafkaSASLPasswordFileEnvVar,
+	}
+	// saved holds the original values of the variables which were set
+	saved := map[string]string{}
+
+	setEnv := func(vars map[string]string) {
+		for k, v := range vars {
+			Expect(os.Setenv(k, v)).To(Succeed())
+		}
+	}
+
+	BeforeEach(func() {
+		saved = map[string]string{}
+		for _, v := range envVars {
+			if value, ok := os.LookupEnv(v); ok {
+				saved[v] = value
+			}
+			Expect(os.Unsetenv(v)).To(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		for _, v := range envVars {
+			Expect(os.Unsetenv(v)).To(Succeed())
+		}
+		for k, v := range saved {
+			Expect(os.Setenv(k, v)).To(Succeed())
+		}
+	})
+
+	It("should report every missing and malformed variable", func() {
+		setEnv(map[string]string{
+			LogLevelEnvVar:           "WARN",
+			BufferSizeEnvVar:         "-1",
+			OutputEnvVar:             "file",
+			FormatEnvVar:             "xml",
+			KafkaTLSEnabledEnvVar:    "yes",
+			KafkaSASLMechanismEnvVar: "PLAIN",
+			KafkaSASLUsernameEnvVar:  "user",
+		})
+
+		_, err := InitFromEnv(context.Background(), "testsvc")
+		Expect(err).To(HaveOccurred())
+		envErr, ok := err.(*EnvError)
+		Expect(ok).To(BeTrue())
+
+		names := []string{}
+		for _, v := range envErr.Vars {
+			names = append(names, v.Name)
+		}
+		Expect(names).To(ConsistOf(
+			LogLevelEnvVar,
+			BufferSizeEnvVar,
+			OutputEnvVar,
+			FormatEnvVar,
+			KafkaBrokersEnvVar,
+			KafkaTopicEnvVar,
+			KafkaTLSEnabledEnvVar,
+			KafkaSASLPasswordEnvVar,
+		))
+		Expect(err.Error()).To(ContainSubstring(
+			KafkaBrokersEnvVar + ": required, but is not set",
+		))
+	})
+
+	It("should create Logger with stdout sink without Kafka variables", func() {
+		setEnv(map[string]string{
+			LogSinkEnvVar: "stdout",
+			OutputEnvVar:  "none",
+			FormatEnvVar:  "logfmt",
+		})
+
+		l, err := InitFromEnv(context.Background(), "testsvc")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(l.(*logger).enableOutput).To(BeFalse())
+		Expect(l.(*logger).formatter).To(Equal(LogfmtFormatter{}))
+	})
+
+	It("should read TLS and SASL settings", func() {
+		dir, err := ioutil.TempDir("", "envconfig")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		certFile, keyFile := writeTestCertFiles(dir)
+		passwordFile := filepath.Join(dir, "password")
+		Expect(ioutil.WriteFile(passwordFile, []byte("pass"), 0600)).To(Succeed())
+
+		setEnv(map[string]string{
+			KafkaTLSEnabledEnvVar:       "true",
+			KafkaTLSCAFileEnvVar:        certFile,
+			KafkaTLSCertFileEnvVar:      certFile,
+			KafkaTLSKeyFileEnvVar:       keyFile,
+			KafkaTLSServerNameEnvVar:    "kafka.internal",
+			KafkaSASLMechanismEnvVar:    "SCRAM-SHA-256",
+			KafkaSASLUsernameEnvVar:     "user",
+			KafkaSASLPasswordFileEnvVar: passwordFile,
+		})
+
+		r := &envReader{}
+		config := sarama.NewConfig()
+		r.kafkaSecurity(config)
+		Expect(r.errs).To(BeEmpty())
+		Expect(config.Net.TLS.Enable).To(BeTrue())
+		Expect(config.Net.TLS.Config.RootCAs).ToNot(BeNil())
+		Expect(config.Net.TLS.Config.Certificates).To(HaveLen(1))
+		Expect(config.Net.TLS.Config.ServerName).To(Equal("kafka.internal"))
+		Expect(config.Net.SASL.Enable).To(BeTrue())
+		Expect(config.Net.SASL.Mechanism).To(Equal(sarama.SASLMechanism("SCRAM-SHA-256")))
+		Expect(config.Net.SASL.SCRAMClientGeneratorFunc).ToNot(BeNil())
+		Expect(config.Net.SASL.User).To(Equal("user"))
+		Expect(config.Net.SASL.Password).To(Equal("pass"))
+	})
+
+	It("should report unreadable TLS files", func() {
+		setEnv(map[string]string{
+			KafkaTLSEnabledEnvVar: "true",
+			KafkaTLSCAFileEnvVar:  "/nonexistent/ca.pem",
+		})
+
+		r := &envReader{}
+		r.kafkaSecurity(sarama.NewConfig())
+		Expect(r.errs).To(HaveLen(1))
+		Expect(r.errs[0].Name).To(Equal(KafkaTLSEnabledEnvVar))
+		Expect(r.errs[0].Reason).To(ContainSubstring("Error reading CA-file"))
+	})
+
+	It("should report incomplete TLS key-pair", func() {
+		setEnv(map[string]string{
+			KafkaTLSEnabledEnvVar:  "true",
+			KafkaTLSCertFileEnvVar: "/nonexistent/cert.pem",
+		})
+
+		r := &envReader{}
+		r.kafkaSecurity(sarama.NewConfig())
+		Expect(r.errs).To(HaveLen(1))
+		Expect(r.errs[0].Name).To(Equal(KafkaTLSKeyFileEnvVar))
+	})
+})
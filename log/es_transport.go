@@ -0,0 +1,244 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// esBulkPath is the path of Elasticsearch's bulk API.
+const esBulkPath = "/_bulk"
+
+// ESConfig configures the ESTransport.
+type ESConfig struct {
+	// HTTPConfig configures the requests and batching.
+	// URL is the Elasticsearch base-URL, such as "http://localhost:9200",
+	// to which the bulk-path is appended if URL has no path.
+	// Retry also applies to retrying the failed documents of a bulk-request.
+	HTTPConfig
+	// IndexPrefix is prepended to the date to form index-names.
+	// Default is "logs-".
+	IndexPrefix string
+	// IndexDateFormat is the Go time-layout for date in index-names.
+	// Default is "2006.01.02", which creates daily indices.
+	IndexDateFormat string
+}
+
+// ESTransport indexes log-entries into Elasticsearch using the bulk API.
+// The entries are mapped to Elastic Common Schema (ECS) fields.
+type ESTransport struct {
+	config ESConfig
+	sender *httpSender
+	batch  *batchSender
+}
+
+// esDocument is a log-entry mapped to ECS fields.
+type esDocument struct {
+	Timestamp string     `json:"@timestamp"`
+	Message   string     `json:"message,omitempty"`
+	Log       *esLog     `json:"log,omitempty"`
+	Service   *esService `json:"service,omitempty"`
+	Event     *esEvent   `json:"event,omitempty"`
+	Error     *esError   `json:"error,omitempty"`
+	// Attachments are only set at DEBUG level.
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+type esLog struct {
+	Level string `json:"level"`
+}
+
+type esService struct {
+	Name string `json:"name"`
+}
+
+type esEvent struct {
+	Action string `json:"action"`
+}
+
+type esError struct {
+	Code string `json:"code"`
+}
+
+// esBulkResponse is the part of bulk-response used to find failed documents.
+type esBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// NewESTransport creates a Transport indexing batches of entries into Elasticsearch.
+func NewESTransport(config ESConfig) (*ESTransport, error) {
+	if config.IndexPrefix == "" {
+		config.IndexPrefix = "logs-"
+	}
+	if config.IndexDateFormat == "" {
+		config.IndexDateFormat = "2006.01.02"
+	}
+	if strings.ToLower(config.IndexPrefix) != config.IndexPrefix {
+		return nil, errors.New("IndexPrefix must be lowercase")
+	}
+
+	u, err := url.Parse(config.URL)
+	if err == nil && strings.Trim(u.Path, "/") == "" {
+		u.Path = esBulkPath
+		config.URL = u.String()
+	}
+
+	sender, bc, err := newHTTPSender(config.HTTPConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &ESTransport{
+		config: config,
+		sender: sender,
+	}
+	t.batch = newBatchSender(redactURL(config.URL), bc, t.send)
+	return t, nil
+}
+
+// esDoc maps the Record logged at ts to ECS fields.
+func esDoc(r *Record, ts time.Time) esDocument {
+	doc := esDocument{
+		Timestamp:   ts.Format(time.RFC3339Nano),
+		Message:     strings.TrimRight(r.Entry.Description, "\n"),
+		Attachments: r.Attachments,
+	}
+	if r.Entry.Level != "" {
+		doc.Log = &esLog{Level: r.Entry.Level}
+	}
+	if r.Entry.ServiceName != "" {
+		doc.Service = &esService{Name: r.Entry.ServiceName}
+	}
+	if r.Entry.Action != "" {
+		doc.Event = &esEvent{Action: r.Entry.Action}
+	}
+	if r.Entry.ErrorCode != 0 {
+		doc.Error = &esError{Code: strconv.Itoa(r.Entry.ErrorCode)}
+	}
+	return doc
+}
+
+// bulkItems creates the action and source lines for each Record.
+func (t *ESTransport) bulkItems(records []*Record) ([][]byte, error) {
+	items := make([][]byte, len(records))
+	for i, r := range records {
+		ts := r.Time
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		ts = ts.UTC()
+		doc := esDoc(r, ts)
+		action := map[string]map[string]string{
+			"index": {
+				"_index": t.config.IndexPrefix + ts.Format(t.config.IndexDateFormat),
+			},
+		}
+
+		actionLine, err := json.Marshal(action)
+		if err != nil {
+			err = errors.Wrap(err, "Error marshalling bulk-action")
+			return nil, err
+		}
+		docLine, err := json.Marshal(doc)
+		if err != nil {
+			err = errors.Wrap(err, "Error marshalling document")
+			return nil, err
+		}
+
+		item := append(actionLine, '\n')
+		item = append(item, docLine...)
+		items[i] = append(item, '\n')
+	}
+	return items, nil
+}
+
+// send indexes the Records, retrying only the documents which failed with
+// a retryable status.
+func (t *ESTransport) send(records []*Record) error {
+	items, err := t.bulkItems(records)
+	if err != nil {
+		return err
+	}
+
+	retry := t.sender.retry
+	failed := 0
+	var lastErr json.RawMessage
+	for attempt := 0; ; attempt++ {
+		body := bytes.Join(items, nil)
+		respBody, err := t.sender.sendForResponse(body, "application/x-ndjson")
+		if err != nil {
+			return err
+		}
+
+		resp := esBulkResponse{}
+		err = json.Unmarshal(respBody, &resp)
+		if err != nil {
+			err = errors.Wrap(err, "Error parsing bulk-response")
+			return err
+		}
+		if !resp.Errors {
+			break
+		}
+		if len(resp.Items) != len(items) {
+			return errors.Errorf(
+				"bulk-response has %d items for %d documents", len(resp.Items), len(items),
+			)
+		}
+
+		retryItems := [][]byte{}
+		for i, item := range resp.Items {
+			for _, result := range item {
+				if result.Status >= 200 && result.Status < 300 {
+					continue
+				}
+				lastErr = result.Error
+				if isRetryableStatus(result.Status) {
+					retryItems = append(retryItems, items[i])
+				} else {
+					failed++
+				}
+			}
+		}
+
+		if len(retryItems) == 0 {
+			break
+		}
+		if attempt >= retry.MaxRetries {
+			failed += len(retryItems)
+			break
+		}
+		items = retryItems
+		if !t.sender.wait(retry.backoff(attempt)) {
+			failed += len(retryItems)
+			break
+		}
+	}
+
+	if failed > 0 {
+		return errors.Errorf(
+			"%d documents failed to index, last error: %s", failed, string(lastErr),
+		)
+	}
+	return nil
+}
+
+// Write adds the Record to current batch.
+func (t *ESTransport) Write(r *Record) error {
+	t.batch.add(r)
+	return nil
+}
+
+// Close indexes the remaining entries and waits for requests in flight.
+func (t *ESTransport) Close() error {
+	t.sender.closeAfter(t.batch.close)
+	return nil
+}
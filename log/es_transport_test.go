@@ -0,0 +1,206 @@
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TerrexTech/go-common-models/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ESTransport", func() {
+	var (
+		server *httptest.Server
+		// statuses returns the item-statuses for a bulk-request with the
+		// provided documents
+		statuses func(attempt int, docs []map[string]interface{}) []int
+
+		lock     sync.Mutex
+		requests [][]map[string]interface{}
+		indices  []string
+	)
+
+	bulkRequests := func() [][]map[string]interface{} {
+		lock.Lock()
+		defer lock.Unlock()
+		return requests
+	}
+
+	BeforeEach(func() {
+		requests = nil
+		indices = nil
+		statuses = func(_ int, docs []map[string]interface{}) []int {
+			s := make([]int, len(docs))
+			for i := range s {
+				s[i] = http.StatusCreated
+			}
+			return s
+		}
+
+		server = httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				defer GinkgoRecover()
+				Expect(r.URL.Path).To(Equal(esBulkPath))
+				Expect(r.Header.Get("Content-Type")).To(Equal("application/x-ndjson"))
+
+				docs := []map[string]interface{}{}
+				scanner := bufio.NewScanner(r.Body)
+				for scanner.Scan() {
+					action := map[string]map[string]string{}
+					err := json.Unmarshal(scanner.Bytes(), &action)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(scanner.Scan()).To(BeTrue())
+					doc := map[string]interface{}{}
+					err = json.Unmarshal(scanner.Bytes(), &doc)
+					Expect(err).ToNot(HaveOccurred())
+
+					lock.Lock()
+					indices = append(indices, action["index"]["_index"])
+					lock.Unlock()
+					docs = append(docs, doc)
+				}
+
+				lock.Lock()
+				attempt := len(requests)
+				requests = append(requests, docs)
+				lock.Unlock()
+
+				items := []string{}
+				hasErrors := false
+				for _, s := range statuses(attempt, docs) {
+					items = append(items, fmt.Sprintf(
+						`{"index":{"status":%d,"error":{"type":"test_error"}}}`, s,
+					))
+					if s >= 300 {
+						hasErrors = true
+					}
+				}
+				fmt.Fprintf(
+					w, `{"errors":%t,"items":[%s]}`, hasErrors, strings.Join(items, ","),
+				)
+			},
+		))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newTransport := func() *ESTransport {
+		t, err := NewESTransport(ESConfig{
+			HTTPConfig: HTTPConfig{
+				URL: server.URL,
+				Retry: RetryConfig{
+					MinBackoff: time.Millisecond,
+				},
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		return t
+	}
+
+	writeEntries := func(t *ESTransport, descriptions ...string) {
+		for _, d := range descriptions {
+			err := t.Write(&Record{
+				Entry: model.LogEntry{
+					Action:      "test-action",
+					Description: d,
+					ErrorCode:   4,
+					Level:       "ERROR",
+					ServiceName: "testsvc",
+				},
+				Time: time.Date(2018, 11, 9, 20, 35, 50, 0, time.UTC),
+			})
+			Expect(err).ToNot(HaveOccurred())
+		}
+	}
+
+	It("should index entries with ECS fields into date-based indices", func() {
+		t := newTransport()
+		writeEntries(t, "test-description\n")
+		Expect(t.Close()).To(Succeed())
+
+		Expect(bulkRequests()).To(HaveLen(1))
+		Expect(bulkRequests()[0]).To(Equal([]map[string]interface{}{
+			{
+				"@timestamp": "2018-11-09T20:35:50Z",
+				"message":    "test-description",
+				"log":        map[string]interface{}{"level": "ERROR"},
+				"service":    map[string]interface{}{"name": "testsvc"},
+				"event":      map[string]interface{}{"action": "test-action"},
+				"error":      map[string]interface{}{"code": "4"},
+			},
+		}))
+		Expect(indices).To(Equal([]string{"logs-2018.11.09"}))
+	})
+
+	It("should index the attachments at DEBUG level", func() {
+		t := newTransport()
+		err := t.Write(&Record{
+			Entry: model.LogEntry{
+				Description: "test-description",
+				Level:       "DEBUG",
+			},
+			Time:   time.Date(2018, 11, 9, 20, 35, 50, 0, time.UTC),
+			Caller: "test.go:12",
+			Attachments: []Attachment{
+				{Type: "map[string]int", Value: json.RawMessage(`{"id":1}`)},
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(t.Close()).To(Succeed())
+
+		Expect(bulkRequests()).To(HaveLen(1))
+		Expect(bulkRequests()[0][0]["attachments"]).To(Equal([]interface{}{
+			map[string]interface{}{
+				"index": 0.0,
+				"type":  "map[string]int",
+				"value": map[string]interface{}{"id": 1.0},
+			},
+		}))
+	})
+
+	It("should retry only the documents which failed with retryable status", func() {
+		statuses = func(attempt int, docs []map[string]interface{}) []int {
+			if attempt > 0 {
+				return []int{http.StatusCreated}
+			}
+			return []int{
+				http.StatusCreated,
+				http.StatusTooManyRequests,
+				http.StatusBadRequest,
+			}
+		}
+
+		t := newTransport()
+		writeEntries(t, "first", "second", "third")
+		Expect(t.Close()).To(Succeed())
+
+		reqs := bulkRequests()
+		Expect(reqs).To(HaveLen(2))
+		Expect(reqs[0]).To(HaveLen(3))
+		Expect(reqs[1]).To(HaveLen(1))
+		Expect(reqs[1][0]["message"]).To(Equal("second"))
+	})
+
+	It("should report documents still failing after retries", func() {
+		statuses = func(_ int, docs []map[string]interface{}) []int {
+			return []int{http.StatusServiceUnavailable}
+		}
+
+		t := newTransport()
+		records := []*Record{{Entry: model.LogEntry{Description: "test"}}}
+		err := t.send(records)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("1 documents failed"))
+		Expect(bulkRequests()).To(HaveLen(4))
+		Expect(t.Close()).To(Succeed())
+	})
+})
@@ -0,0 +1,364 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SyncPolicy determines when the log-file is flushed to disk using fsync.
+type SyncPolicy int
+
+const (
+	// SyncNever leaves flushing to the operating system. This is the default.
+	SyncNever SyncPolicy = iota
+	// SyncAlways flushes the file after every entry.
+	SyncAlways
+	// SyncInterval flushes the file on first write after SyncInterval has
+	// passed since last flush.
+	SyncInterval
+)
+
+// backupTimeFormat is the timestamp-format used in names of rotated files.
+// This sorts lexically in chronological order.
+const backupTimeFormat = "20060102T150405.000"
+
+// FileConfig configures the FileTransport.
+type FileConfig struct {
+	// Path is the log-file path. Rotated files are created in same directory,
+	// as <name>-<timestamp><ext>, such as "app-20181109T203550.000.log".
+	Path string
+	// Formatter renders the log-entries. Default is JSONFormatter.
+	Formatter Formatter
+	// MaxSize is the size in bytes after which the file is rotated.
+	// Zero disables size-based rotation.
+	MaxSize int64
+	// MaxAge is the duration after which the file is rotated.
+	// Zero disables age-based rotation.
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files to retain.
+	// Zero retains all rotated files.
+	MaxBackups int
+	// Compress gzips the rotated files.
+	Compress bool
+	// Sync is the fsync policy. Default is SyncNever.
+	Sync SyncPolicy
+	// SyncInterval is the interval for SyncInterval policy.
+	SyncInterval time.Duration
+}
+
+// FileTransport writes log-entries to a local file, rotating it by size and age.
+type FileTransport struct {
+	config FileConfig
+
+	// file is nil after Close, or if it could not be reopened while rotating,
+	// in which case it is opened again on next Write.
+	file     *os.File
+	closed   bool
+	size     int64
+	openedAt time.Time
+	syncedAt time.Time
+}
+
+// NewFileTransport creates a Transport writing to the file at config.Path.
+// The file is appended to if it already exists.
+func NewFileTransport(config FileConfig) (*FileTransport, error) {
+	if config.Path == "" {
+		return nil, errors.New("empty file-path provided")
+	}
+	if config.MaxSize < 0 {
+		return nil, errors.New("MaxSize cannot be negative")
+	}
+	if config.MaxAge < 0 {
+		return nil, errors.New("MaxAge cannot be negative")
+	}
+	if config.MaxBackups < 0 {
+		return nil, errors.New("MaxBackups cannot be negative")
+	}
+	if config.Sync == SyncInterval && config.SyncInterval <= 0 {
+		return nil, errors.New("SyncInterval must be positive for SyncInterval policy")
+	}
+	if config.Formatter == nil {
+		config.Formatter = JSONFormatter{}
+	}
+
+	t := &FileTransport{
+		config: config,
+	}
+	err := t.open()
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// open opens the log-file for appending, creating it if required.
+func (t *FileTransport) open() error {
+	err := os.MkdirAll(filepath.Dir(t.config.Path), 0755)
+	if err != nil {
+		err = errors.Wrap(err, "Error creating log-directory")
+		return err
+	}
+
+	file, err := os.OpenFile(
+		t.config.Path,
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
+		0644,
+	)
+	if err != nil {
+		err = errors.Wrap(err, "Error opening log-file")
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		err = errors.Wrap(err, "Error reading log-file info")
+		return err
+	}
+
+	t.file = file
+	t.size = info.Size()
+	t.openedAt = time.Now()
+	t.syncedAt = t.openedAt
+	return nil
+}
+
+// Write formats the Record and appends it to log-file,
+// rotating the file first if required. If rotation fails, the Record is
+// still appended to the current file, and the rotation is retried later.
+func (t *FileTransport) Write(r *Record) error {
+	if t.closed {
+		return errors.New("log-file is closed")
+	}
+	if t.file == nil {
+		err := t.open()
+		if err != nil {
+			return err
+		}
+	}
+
+	b, err := t.config.Formatter.Format(r)
+	if err != nil {
+		err = errors.Wrap(err, "Error formatting log-entry")
+		return err
+	}
+
+	var rotateErr error
+	if t.shouldRotate(int64(len(b))) {
+		rotateErr = t.rotate()
+		if t.file == nil {
+			return rotateErr
+		}
+	}
+
+	n, err := t.file.Write(b)
+	t.size += int64(n)
+	if err != nil {
+		err = errors.Wrap(err, "Error writing to log-file")
+		return err
+	}
+
+	switch t.config.Sync {
+	case SyncAlways:
+		err = t.sync()
+	case SyncInterval:
+		if time.Since(t.syncedAt) >= t.config.SyncInterval {
+			err = t.sync()
+		}
+	}
+	if err == nil {
+		err = rotateErr
+	}
+	return err
+}
+
+// shouldRotate checks if the file must be rotated before writing n more bytes.
+// A file is never rotated while empty.
+func (t *FileTransport) shouldRotate(n int64) bool {
+	if t.size == 0 {
+		return false
+	}
+	if t.config.MaxSize > 0 && t.size+n > t.config.MaxSize {
+		return true
+	}
+	if t.config.MaxAge > 0 && time.Since(t.openedAt) >= t.config.MaxAge {
+		return true
+	}
+	return false
+}
+
+func (t *FileTransport) sync() error {
+	t.syncedAt = time.Now()
+	err := t.file.Sync()
+	if err != nil {
+		err = errors.Wrap(err, "Error syncing log-file")
+	}
+	return err
+}
+
+// rotate renames the current file to a timestamped backup, opens a new file,
+// and applies compression and retention to backups. The current file is
+// reopened if it cannot be renamed.
+func (t *FileTransport) rotate() error {
+	err := t.file.Close()
+	t.file = nil
+	if err != nil {
+		err = errors.Wrap(err, "Error closing log-file for rotation")
+		return err
+	}
+
+	// Avoid overwriting a backup rotated within the same millisecond
+	at := time.Now()
+	backup := t.backupName(at)
+	for fileExists(backup) || fileExists(backup+".gz") {
+		at = at.Add(time.Millisecond)
+		backup = t.backupName(at)
+	}
+	err = os.Rename(t.config.Path, backup)
+	if err != nil {
+		err = errors.Wrap(err, "Error renaming log-file for rotation")
+		if openErr := t.open(); openErr != nil {
+			log.Println(openErr)
+		}
+		return err
+	}
+
+	err = t.open()
+	if err != nil {
+		return err
+	}
+
+	if t.config.Compress {
+		err = compressFile(backup)
+		if err != nil {
+			return err
+		}
+	}
+	return t.removeOldBackups()
+}
+
+// backupName returns the rotated-file name for provided time.
+func (t *FileTransport) backupName(at time.Time) string {
+	dir := filepath.Dir(t.config.Path)
+	name, ext := t.nameParts()
+	timestamp := at.UTC().Format(backupTimeFormat)
+	return filepath.Join(dir, name+"-"+timestamp+ext)
+}
+
+// nameParts splits the log-file name into name and extension.
+func (t *FileTransport) nameParts() (string, string) {
+	base := filepath.Base(t.config.Path)
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext), ext
+}
+
+// backups lists the rotated files, oldest first.
+func (t *FileTransport) backups() ([]string, error) {
+	dir := filepath.Dir(t.config.Path)
+	name, ext := t.nameParts()
+
+	files, err := filepath.Glob(filepath.Join(dir, name+"-*"+ext+"*"))
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []string{}
+	for _, f := range files {
+		ts := strings.TrimPrefix(filepath.Base(f), name+"-")
+		ts = strings.TrimSuffix(ts, ".gz")
+		ts = strings.TrimSuffix(ts, ext)
+		if _, err := time.Parse(backupTimeFormat, ts); err == nil {
+			backups = append(backups, f)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// removeOldBackups removes the oldest rotated files exceeding MaxBackups.
+func (t *FileTransport) removeOldBackups() error {
+	if t.config.MaxBackups == 0 {
+		return nil
+	}
+
+	backups, err := t.backups()
+	if err != nil {
+		err = errors.Wrap(err, "Error listing rotated log-files")
+		return err
+	}
+	for len(backups) > t.config.MaxBackups {
+		err = os.Remove(backups[0])
+		if err != nil {
+			err = errors.Wrap(err, "Error removing rotated log-file")
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// fileExists checks if a file exists at path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// compressFile gzips the file at path to path.gz, and removes the original.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		err = errors.Wrap(err, "Error opening rotated log-file for compression")
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		err = errors.Wrap(err, "Error creating compressed log-file")
+		return err
+	}
+
+	gw := gzip.NewWriter(dst)
+	_, err = io.Copy(gw, src)
+	if err == nil {
+		err = gw.Close()
+	}
+	if err == nil {
+		err = dst.Close()
+	} else {
+		dst.Close()
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		err = errors.Wrap(err, "Error compressing rotated log-file")
+		return err
+	}
+
+	src.Close()
+	return os.Remove(path)
+}
+
+// Close flushes and closes the log-file.
+func (t *FileTransport) Close() error {
+	t.closed = true
+	if t.file == nil {
+		return nil
+	}
+	err := t.file.Sync()
+	closeErr := t.file.Close()
+	t.file = nil
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		err = errors.Wrap(err, "Error closing log-file")
+	}
+	return err
+}
@@ -0,0 +1,206 @@
+package log
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/TerrexTech/go-common-models/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FileTransport", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "logtransport")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		err := os.RemoveAll(dir)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	writeEntries := func(t *FileTransport, count int) {
+		for i := 0; i < count; i++ {
+			err := t.Write(&Record{
+				Entry: model.LogEntry{
+					Description: strings.Repeat("a", 50),
+					Level:       "INFO",
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+		}
+	}
+
+	listFiles := func() []string {
+		files, err := filepath.Glob(filepath.Join(dir, "*"))
+		Expect(err).ToNot(HaveOccurred())
+		return files
+	}
+
+	It("should write entries as JSON-lines", func() {
+		path := filepath.Join(dir, "app.log")
+		t, err := NewFileTransport(FileConfig{
+			Path: path,
+			Sync: SyncAlways,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		writeEntries(t, 3)
+		Expect(t.Close()).To(Succeed())
+
+		file, err := os.Open(path)
+		Expect(err).ToNot(HaveOccurred())
+		defer file.Close()
+
+		lines := 0
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			e := model.LogEntry{}
+			err = json.Unmarshal(scanner.Bytes(), &e)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(e.Level).To(Equal("INFO"))
+			lines++
+		}
+		Expect(lines).To(Equal(3))
+	})
+
+	It("should rotate by size and retain configured number of backups", func() {
+		path := filepath.Join(dir, "app.log")
+		t, err := NewFileTransport(FileConfig{
+			Path:       path,
+			MaxSize:    100,
+			MaxBackups: 2,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		writeEntries(t, 6)
+		Expect(t.Close()).To(Succeed())
+
+		backups, err := t.backups()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(backups).To(HaveLen(2))
+		Expect(listFiles()).To(HaveLen(3))
+	})
+
+	It("should rotate by age", func() {
+		path := filepath.Join(dir, "app.log")
+		t, err := NewFileTransport(FileConfig{
+			Path:   path,
+			MaxAge: 10 * time.Millisecond,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		writeEntries(t, 1)
+		time.Sleep(20 * time.Millisecond)
+		writeEntries(t, 1)
+		Expect(t.Close()).To(Succeed())
+
+		Expect(listFiles()).To(HaveLen(2))
+	})
+
+	It("should keep writing if rotation fails", func() {
+		path := filepath.Join(dir, "app.log")
+		t, err := NewFileTransport(FileConfig{
+			Path:    path,
+			MaxSize: 100,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		writeEntries(t, 1)
+		// Renaming the removed file fails, so it is reopened at path
+		Expect(os.Remove(path)).To(Succeed())
+		err = t.Write(&Record{Entry: model.LogEntry{Description: strings.Repeat("b", 50)}})
+		Expect(err).To(HaveOccurred())
+		Expect(t.file).ToNot(BeNil())
+
+		// The file is opened again on next Write if reopening failed
+		Expect(t.file.Close()).To(Succeed())
+		t.file = nil
+		writeEntries(t, 1)
+		Expect(t.Close()).To(Succeed())
+		Expect(t.Write(&Record{})).To(HaveOccurred())
+
+		// The first entry was removed, and the later ones are rotated normally
+		content := ""
+		for _, f := range listFiles() {
+			b, err := ioutil.ReadFile(f)
+			Expect(err).ToNot(HaveOccurred())
+			content += string(b)
+		}
+		Expect(content).To(ContainSubstring(strings.Repeat("b", 50)))
+		Expect(strings.Count(content, "\n")).To(Equal(2))
+	})
+
+	It("should gzip rotated files", func() {
+		path := filepath.Join(dir, "app.log")
+		t, err := NewFileTransport(FileConfig{
+			Path:     path,
+			MaxSize:  100,
+			Compress: true,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		writeEntries(t, 2)
+		Expect(t.Close()).To(Succeed())
+
+		backups, err := t.backups()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(backups).To(HaveLen(1))
+		Expect(backups[0]).To(HaveSuffix(".log.gz"))
+
+		file, err := os.Open(backups[0])
+		Expect(err).ToNot(HaveOccurred())
+		defer file.Close()
+		gr, err := gzip.NewReader(file)
+		Expect(err).ToNot(HaveOccurred())
+		b, err := ioutil.ReadAll(gr)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(b)).To(ContainSubstring(`"level":"INFO"`))
+	})
+
+	It("should return error on invalid config", func() {
+		_, err := NewFileTransport(FileConfig{})
+		Expect(err).To(HaveOccurred())
+
+		_, err = NewFileTransport(FileConfig{
+			Path: filepath.Join(dir, "app.log"),
+			Sync: SyncInterval,
+		})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should be usable as Logger's transport", func() {
+		err := os.Setenv(LogLevelEnvVar, "INFO")
+		Expect(err).ToNot(HaveOccurred())
+
+		path := filepath.Join(dir, "app.log")
+		t, err := NewFileTransport(FileConfig{
+			Path: path,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		logger, err := InitTransport(ctx, "testsvc", t, TransportConfig{Name: "file"})
+		Expect(err).ToNot(HaveOccurred())
+		logger.DisableOutput()
+		logger.I(Entry{Description: "test-description"})
+		cancel()
+
+		Eventually(func() string {
+			b, err := ioutil.ReadFile(path)
+			Expect(err).ToNot(HaveOccurred())
+			return string(b)
+		}).Should(ContainSubstring(`"serviceName":"testsvc"`))
+	})
+})
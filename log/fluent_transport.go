@@ -0,0 +1,296 @@
+package log
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FluentMode is the event-mode of Fluent Forward protocol.
+type FluentMode int
+
+const (
+	// FluentForward sends the events of a tag as array of [time, record] entries.
+	FluentForward FluentMode = iota
+	// FluentPackedForward sends the events of a tag as a single binary of
+	// concatenated MessagePack entries, which is cheaper to decode for the receiver.
+	FluentPackedForward
+)
+
+// FluentConfig configures the FluentTransport.
+type FluentConfig struct {
+	// Network is either "tcp" or "unix".
+	Network string
+	// Address is the host:port, or socket-path, of Fluentd/Fluent Bit forward input.
+	Address string
+	// TagPrefix is prepended to tags, which have the format
+	// "<TagPrefix>.<serviceName>.<level>". Default is "log".
+	TagPrefix string
+	// Mode is the event-mode. Default is FluentForward.
+	Mode FluentMode
+	// RequireAck makes the receiver acknowledge every chunk, and chunks which
+	// are not acknowledged are resent. This provides at-least-once delivery.
+	RequireAck bool
+	// AckTimeout is the time to wait for an acknowledgement. Default is 5 seconds.
+	AckTimeout time.Duration
+	// BatchSize is the maximum number of entries per chunk. Default is 100.
+	BatchSize int
+	// FlushInterval is the interval after which incomplete batches are sent.
+	// Default is 1 second.
+	FlushInterval time.Duration
+	// DialTimeout is the timeout for connecting to receiver. Default is 5 seconds.
+	DialTimeout time.Duration
+	// WriteTimeout is the timeout for writing a chunk. Default is 5 seconds.
+	WriteTimeout time.Duration
+	// MaxReconnects is the number of reconnect-attempts when writing a chunk
+	// fails, or its acknowledgement is not received. Default is 1.
+	MaxReconnects int
+}
+
+// FluentTransport sends log-entries to Fluentd or Fluent Bit using the
+// Fluent Forward protocol, such as to a logging sidecar.
+type FluentTransport struct {
+	config FluentConfig
+	conn   *reconnectingConn
+	batch  *batchSender
+}
+
+// NewFluentTransport creates a Transport sending batches of entries to a
+// Fluent Forward receiver. The connection is established lazily on first send.
+func NewFluentTransport(config FluentConfig) (*FluentTransport, error) {
+	if config.Network != "tcp" && config.Network != "unix" {
+		return nil, errors.Errorf("invalid Fluent network: %s", config.Network)
+	}
+	if config.Address == "" {
+		return nil, errors.New("empty Fluent address provided")
+	}
+	if config.Mode != FluentForward && config.Mode != FluentPackedForward {
+		return nil, errors.Errorf("invalid Fluent mode: %d", config.Mode)
+	}
+	if config.BatchSize < 0 {
+		return nil, errors.New("BatchSize cannot be negative")
+	}
+	if config.TagPrefix == "" {
+		config.TagPrefix = "log"
+	}
+	if config.AckTimeout <= 0 {
+		config.AckTimeout = 5 * time.Second
+	}
+	if config.BatchSize == 0 {
+		config.BatchSize = 100
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = 5 * time.Second
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = 5 * time.Second
+	}
+	if config.MaxReconnects <= 0 {
+		config.MaxReconnects = 1
+	}
+
+	t := &FluentTransport{
+		config: config,
+		conn: &reconnectingConn{
+			network:       config.Network,
+			address:       config.Address,
+			dialTimeout:   config.DialTimeout,
+			writeTimeout:  config.WriteTimeout,
+			maxReconnects: config.MaxReconnects,
+		},
+	}
+	// The connection is not safe for concurrent use, so chunks are sent serially
+	t.batch = newBatchSender(config.Address, batchConfig{
+		size:        config.BatchSize,
+		interval:    config.FlushInterval,
+		concurrency: 1,
+	}, t.send)
+	return t, nil
+}
+
+// tag returns the Fluent tag for the Record.
+func (t *FluentTransport) tag(r *Record) string {
+	svc := r.Entry.ServiceName
+	if svc == "" {
+		svc = "unknown"
+	}
+	level := strings.ToLower(r.Entry.Level)
+	if level == "" {
+		level = "unknown"
+	}
+	return t.config.TagPrefix + "." + svc + "." + level
+}
+
+// writeFluentEvent writes the Record as [time, record] entry. The Attachments
+// are added to the record as JSON, if any.
+func writeFluentEvent(b *msgpackBuffer, r *Record) {
+	ts := r.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	b.writeArrayHeader(2)
+	b.writeEventTime(ts)
+	attachments := attachmentsJSON(r.Attachments)
+	if attachments == "" {
+		b.writeMapHeader(5)
+	} else {
+		b.writeMapHeader(6)
+	}
+	b.writeString("action")
+	b.writeString(r.Entry.Action)
+	if attachments != "" {
+		b.writeString("attachments")
+		b.writeString(attachments)
+	}
+	b.writeString("description")
+	b.writeString(strings.TrimRight(r.Entry.Description, "\n"))
+	b.writeString("errorCode")
+	b.writeInt(int64(r.Entry.ErrorCode))
+	b.writeString("level")
+	b.writeString(r.Entry.Level)
+	b.writeString("serviceName")
+	b.writeString(r.Entry.ServiceName)
+}
+
+// message encodes the Records of a tag as Forward or PackedForward message.
+func (t *FluentTransport) message(
+	tag string, records []*Record, chunk string,
+) []byte {
+	msg := &msgpackBuffer{}
+	msg.writeArrayHeader(3)
+	msg.writeString(tag)
+
+	if t.config.Mode == FluentPackedForward {
+		entries := &msgpackBuffer{}
+		for _, r := range records {
+			writeFluentEvent(entries, r)
+		}
+		msg.writeBin(entries.Bytes())
+	} else {
+		msg.writeArrayHeader(len(records))
+		for _, r := range records {
+			writeFluentEvent(msg, r)
+		}
+	}
+
+	if chunk == "" {
+		msg.writeMapHeader(1)
+	} else {
+		msg.writeMapHeader(2)
+		msg.writeString("chunk")
+		msg.writeString(chunk)
+	}
+	msg.writeString("size")
+	msg.writeInt(int64(len(records)))
+	return msg.Bytes()
+}
+
+// send sends the Records as one message per tag.
+func (t *FluentTransport) send(records []*Record) error {
+	tags := []string{}
+	byTag := map[string][]*Record{}
+	for _, r := range records {
+		tag := t.tag(r)
+		if _, ok := byTag[tag]; !ok {
+			tags = append(tags, tag)
+		}
+		byTag[tag] = append(byTag[tag], r)
+	}
+
+	for _, tag := range tags {
+		err := t.sendChunk(tag, byTag[tag])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendChunk sends the message for tag, and waits for its acknowledgement
+// if RequireAck is set. Unacknowledged chunks are resent on a new connection.
+func (t *FluentTransport) sendChunk(tag string, records []*Record) error {
+	if !t.config.RequireAck {
+		err := t.conn.write(t.message(tag, records, ""))
+		if err != nil {
+			err = errors.Wrap(err, "Error writing Fluent message")
+		}
+		return err
+	}
+
+	chunkID := make([]byte, 16)
+	_, err := rand.Read(chunkID)
+	if err != nil {
+		err = errors.Wrap(err, "Error generating Fluent chunk-id")
+		return err
+	}
+	chunk := base64.StdEncoding.EncodeToString(chunkID)
+	msg := t.message(tag, records, chunk)
+
+	for attempt := 0; attempt <= t.config.MaxReconnects; attempt++ {
+		err = t.conn.write(msg)
+		if err != nil {
+			err = errors.Wrap(err, "Error writing Fluent message")
+			continue
+		}
+		err = t.readAck(chunk)
+		if err == nil {
+			return nil
+		}
+		t.conn.close()
+	}
+	return err
+}
+
+// maxFluentAckLength limits the lengths in acknowledgement-responses, which
+// only contain the chunk-ID.
+const maxFluentAckLength = 1024
+
+// readAck reads the acknowledgement-response and verifies it matches chunk.
+func (t *FluentTransport) readAck(chunk string) error {
+	decoder := &msgpackDecoder{
+		r: &fluentAckReader{
+			conn:    t.conn,
+			timeout: t.config.AckTimeout,
+		},
+		maxLength: maxFluentAckLength,
+	}
+	resp, err := decoder.decode()
+	if err != nil {
+		err = errors.Wrap(err, "Error reading Fluent acknowledgement")
+		return err
+	}
+	m, ok := resp.(map[string]interface{})
+	if !ok || m["ack"] != chunk {
+		return errors.Errorf("invalid Fluent acknowledgement for chunk %s: %v", chunk, resp)
+	}
+	return nil
+}
+
+// fluentAckReader reads from the connection with the acknowledgement-timeout.
+type fluentAckReader struct {
+	conn    *reconnectingConn
+	timeout time.Duration
+}
+
+func (r *fluentAckReader) Read(b []byte) (int, error) {
+	return r.conn.read(b, r.timeout)
+}
+
+// Write adds the Record to current batch.
+func (t *FluentTransport) Write(r *Record) error {
+	t.batch.add(r)
+	return nil
+}
+
+// Close sends the remaining entries and closes the connection.
+func (t *FluentTransport) Close() error {
+	t.batch.close()
+	return t.conn.close()
+}
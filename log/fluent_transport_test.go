@@ -0,0 +1,261 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/TerrexTech/go-common-models/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FluentTransport", func() {
+	var (
+		listener net.Listener
+		messages chan []interface{}
+		// ack decides whether to acknowledge the nth received message.
+		// The connection is closed without acknowledging otherwise.
+		ack func(n int) bool
+	)
+
+	// serve decodes messages from the listener's connections into msgs
+	serve := func(l net.Listener, msgs chan<- []interface{}, ack func(int) bool) {
+		var (
+			lock     sync.Mutex
+			received int
+		)
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				decoder := &msgpackDecoder{r: bufio.NewReader(conn)}
+				for {
+					v, err := decoder.decode()
+					if err != nil {
+						return
+					}
+					msg := v.([]interface{})
+					msgs <- msg
+
+					lock.Lock()
+					n := received
+					received++
+					lock.Unlock()
+
+					option := msg[2].(map[string]interface{})
+					if chunk, ok := option["chunk"]; ok {
+						if !ack(n) {
+							return
+						}
+						resp := &msgpackBuffer{}
+						resp.writeMapHeader(1)
+						resp.writeString("ack")
+						resp.writeString(chunk.(string))
+						conn.Write(resp.Bytes())
+					}
+				}
+			}()
+		}
+	}
+
+	listen := func(network, address string) {
+		var err error
+		listener, err = net.Listen(network, address)
+		Expect(err).ToNot(HaveOccurred())
+		go serve(listener, messages, ack)
+	}
+
+	BeforeEach(func() {
+		messages = make(chan []interface{}, 10)
+		ack = func(int) bool { return true }
+		listener = nil
+	})
+
+	AfterEach(func() {
+		if listener != nil {
+			listener.Close()
+		}
+	})
+
+	record := func(level string) *Record {
+		return &Record{
+			Entry: model.LogEntry{
+				Action:      "test-action",
+				Description: "test-description\n",
+				ErrorCode:   4,
+				Level:       level,
+				ServiceName: "testsvc",
+			},
+			Time: time.Unix(1541795750, 250),
+		}
+	}
+
+	// events decodes the events of a Forward or PackedForward message.
+	events := func(msg []interface{}) []interface{} {
+		if packed, ok := msg[1].([]byte); ok {
+			decoder := &msgpackDecoder{r: bytes.NewReader(packed)}
+			events := []interface{}{}
+			for {
+				e, err := decoder.decode()
+				if err != nil {
+					return events
+				}
+				events = append(events, e)
+			}
+		}
+		return msg[1].([]interface{})
+	}
+
+	It("should send entries grouped by tag in Forward mode", func() {
+		listen("tcp", "127.0.0.1:0")
+		t, err := NewFluentTransport(FluentConfig{
+			Network: "tcp",
+			Address: listener.Addr().String(),
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(t.Write(record("INFO"))).To(Succeed())
+		Expect(t.Write(record("ERROR"))).To(Succeed())
+		Expect(t.Write(record("INFO"))).To(Succeed())
+		Expect(t.Close()).To(Succeed())
+
+		var msg []interface{}
+		Eventually(messages).Should(Receive(&msg))
+		Expect(msg[0]).To(Equal("log.testsvc.info"))
+		Expect(msg[2]).To(Equal(map[string]interface{}{"size": int64(2)}))
+		evts := events(msg)
+		Expect(evts).To(HaveLen(2))
+
+		event := evts[0].([]interface{})
+		ts := event[0].(msgpackExt)
+		Expect(ts.Type).To(BeEquivalentTo(msgpackEventTimeExt))
+		Expect(binary.BigEndian.Uint32(ts.Data[:4])).To(BeEquivalentTo(1541795750))
+		Expect(binary.BigEndian.Uint32(ts.Data[4:])).To(BeEquivalentTo(250))
+		Expect(event[1]).To(Equal(map[string]interface{}{
+			"action":      "test-action",
+			"description": "test-description",
+			"errorCode":   int64(4),
+			"level":       "INFO",
+			"serviceName": "testsvc",
+		}))
+
+		Eventually(messages).Should(Receive(&msg))
+		Expect(msg[0]).To(Equal("log.testsvc.error"))
+		Expect(events(msg)).To(HaveLen(1))
+	})
+
+	It("should add the attachments at DEBUG level to records", func() {
+		r := record("DEBUG")
+		r.Caller = "test.go:12"
+		r.Attachments = []Attachment{
+			{Type: "string", Value: json.RawMessage(`"test-data"`)},
+		}
+		b := &msgpackBuffer{}
+		writeFluentEvent(b, r)
+
+		decoder := &msgpackDecoder{r: bytes.NewReader(b.Bytes())}
+		event, err := decoder.decode()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(event.([]interface{})[1]).To(Equal(map[string]interface{}{
+			"action":      "test-action",
+			"attachments": `[{"index":0,"type":"string","value":"test-data"}]`,
+			"description": "test-description",
+			"errorCode":   int64(4),
+			"level":       "DEBUG",
+			"serviceName": "testsvc",
+		}))
+	})
+
+	It("should not decode lengths exceeding the maximum", func() {
+		// bin32 and array32 with lengths of 4 GiB-1, without the content
+		for _, packed := range [][]byte{
+			{0xc6, 0xff, 0xff, 0xff, 0xff},
+			{0xdd, 0xff, 0xff, 0xff, 0xff},
+		} {
+			decoder := &msgpackDecoder{
+				r:         bytes.NewReader(packed),
+				maxLength: maxFluentAckLength,
+			}
+			_, err := decoder.decode()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("exceeds maximum"))
+		}
+	})
+
+	It("should send PackedForward messages over Unix socket", func() {
+		dir, err := ioutil.TempDir("", "fluent")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		listen("unix", filepath.Join(dir, "fluent.sock"))
+
+		t, err := NewFluentTransport(FluentConfig{
+			Network:   "unix",
+			Address:   filepath.Join(dir, "fluent.sock"),
+			Mode:      FluentPackedForward,
+			TagPrefix: "k8s",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(t.Write(record("DEBUG"))).To(Succeed())
+		Expect(t.Write(record("DEBUG"))).To(Succeed())
+		Expect(t.Close()).To(Succeed())
+
+		var msg []interface{}
+		Eventually(messages).Should(Receive(&msg))
+		Expect(msg[0]).To(Equal("k8s.testsvc.debug"))
+		Expect(msg[1]).To(BeAssignableToTypeOf([]byte{}))
+		Expect(events(msg)).To(HaveLen(2))
+	})
+
+	It("should resend chunks which are not acknowledged", func() {
+		ack = func(n int) bool {
+			return n > 0
+		}
+		listen("tcp", "127.0.0.1:0")
+
+		t, err := NewFluentTransport(FluentConfig{
+			Network:    "tcp",
+			Address:    listener.Addr().String(),
+			RequireAck: true,
+			AckTimeout: time.Second,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(t.Write(record("INFO"))).To(Succeed())
+		Expect(t.Close()).To(Succeed())
+
+		var first, second []interface{}
+		Eventually(messages).Should(Receive(&first))
+		Eventually(messages).Should(Receive(&second))
+		chunk := first[2].(map[string]interface{})["chunk"]
+		Expect(chunk).ToNot(BeEmpty())
+		Expect(second[2].(map[string]interface{})["chunk"]).To(Equal(chunk))
+		Consistently(messages).ShouldNot(Receive())
+	})
+
+	It("should return error on invalid config", func() {
+		_, err := NewFluentTransport(FluentConfig{
+			Network: "udp",
+			Address: "127.0.0.1:24224",
+		})
+		Expect(err).To(HaveOccurred())
+
+		_, err = NewFluentTransport(FluentConfig{
+			Network: "tcp",
+			Address: "127.0.0.1:24224",
+			Mode:    FluentMode(5),
+		})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,117 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// FormatLimits limits the size of data attached to log-entries at DEBUG level.
+// The limits are applied recursively through the whole value, and the trimmed
+// parts are replaced by truncation markers, such as "...[3 more elements]".
+// Zero disables the respective limit.
+type FormatLimits struct {
+	// ArrayThreshold is the maximum number of elements kept in arrays.
+	ArrayThreshold int
+	// MaxDepth is the maximum nesting of objects and arrays.
+	// Deeper objects and arrays are replaced by a marker.
+	MaxDepth int
+	// MaxStringLength is the maximum length of strings in bytes,
+	// including []byte, which is rendered as base64-string.
+	MaxStringLength int
+	// MaxMapEntries is the maximum number of entries kept in objects,
+	// such as maps and structs. The entries are kept in order of keys.
+	MaxMapEntries int
+	// MaxBytes is the maximum total size of all attachments of an entry.
+	// Attachments exceeding it are replaced by a marker.
+	MaxBytes int
+}
+
+// DefaultFormatLimits are the FormatLimits used by Logger by default.
+var DefaultFormatLimits = FormatLimits{
+	ArrayThreshold:  15,
+	MaxDepth:        10,
+	MaxStringLength: 2048,
+	MaxMapEntries:   50,
+	MaxBytes:        64 * 1024,
+}
+
+func (f FormatLimits) validate() error {
+	switch {
+	case f.ArrayThreshold < 0:
+		return errors.New("ArrayThreshold cannot be negative")
+	case f.MaxDepth < 0:
+		return errors.New("MaxDepth cannot be negative")
+	case f.MaxStringLength < 0:
+		return errors.New("MaxStringLength cannot be negative")
+	case f.MaxMapEntries < 0:
+		return errors.New("MaxMapEntries cannot be negative")
+	case f.MaxBytes < 0:
+		return errors.New("MaxBytes cannot be negative")
+	}
+	return nil
+}
+
+// limit applies the limits to a decoded JSON value at provided depth.
+func (f FormatLimits) limit(v interface{}, depth int, truncated *bool) interface{} {
+	switch t := v.(type) {
+	case string:
+		if f.MaxStringLength == 0 || len(t) <= f.MaxStringLength {
+			return t
+		}
+		*truncated = true
+		n := f.MaxStringLength
+		// Don't split multi-byte characters
+		for n > 0 && !utf8.RuneStart(t[n]) {
+			n--
+		}
+		return fmt.Sprintf("%s...[truncated %d bytes]", t[:n], len(t)-n)
+
+	case []interface{}:
+		if f.MaxDepth > 0 && depth > f.MaxDepth {
+			*truncated = true
+			return fmt.Sprintf("...[array of %d elements exceeds max depth]", len(t))
+		}
+		length := len(t)
+		if f.ArrayThreshold > 0 && length > f.ArrayThreshold {
+			*truncated = true
+			length = f.ArrayThreshold
+		}
+		values := make([]interface{}, length, length+1)
+		for i := range values {
+			values[i] = f.limit(t[i], depth+1, truncated)
+		}
+		if length < len(t) {
+			values = append(values, fmt.Sprintf("...[%d more elements]", len(t)-length))
+		}
+		return values
+
+	case map[string]interface{}:
+		if f.MaxDepth > 0 && depth > f.MaxDepth {
+			*truncated = true
+			return fmt.Sprintf("...[object of %d entries exceeds max depth]", len(t))
+		}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		if f.MaxMapEntries > 0 && len(keys) > f.MaxMapEntries {
+			*truncated = true
+			keys = keys[:f.MaxMapEntries]
+		}
+		values := make(map[string]interface{}, len(keys)+1)
+		for _, k := range keys {
+			values[k] = f.limit(t[k], depth+1, truncated)
+		}
+		if len(keys) < len(t) {
+			values["..."] = fmt.Sprintf("[%d more entries]", len(t)-len(keys))
+		}
+		return values
+
+	default:
+		return v
+	}
+}
@@ -0,0 +1,106 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FormatLimits", func() {
+	type nested struct {
+		Name  string            `json:"name"`
+		Items []int             `json:"items"`
+		Attrs map[string]string `json:"attrs"`
+		Child *nested           `json:"child,omitempty"`
+	}
+
+	It("should apply limits recursively with truncation markers", func() {
+		data := nested{
+			Name:  "héllo world",
+			Items: []int{1, 2, 3, 4},
+			Attrs: map[string]string{"a": "1", "b": "2", "c": "3"},
+			Child: &nested{
+				Name:  "child",
+				Child: &nested{Name: "grandchild"},
+			},
+		}
+		a := newAttachment(0, data, FormatLimits{
+			ArrayThreshold:  2,
+			MaxDepth:        1,
+			MaxStringLength: 3,
+			MaxMapEntries:   2,
+		}, nil)
+		Expect(a.Truncated).To(BeTrue())
+
+		value := map[string]interface{}{}
+		Expect(json.Unmarshal(a.Value, &value)).To(Succeed())
+		Expect(value).To(Equal(map[string]interface{}{
+			"attrs": "...[object of 3 entries exceeds max depth]",
+			"child": "...[object of 4 entries exceeds max depth]",
+			"...":   "[2 more entries]",
+		}))
+
+		a = newAttachment(0, data, FormatLimits{
+			ArrayThreshold:  2,
+			MaxStringLength: 3,
+			MaxMapEntries:   2,
+		}, nil)
+		value = map[string]interface{}{}
+		Expect(json.Unmarshal(a.Value, &value)).To(Succeed())
+		Expect(value).To(Equal(map[string]interface{}{
+			"attrs": map[string]interface{}{"a": "1", "b": "2", "...": "[1 more entries]"},
+			"child": map[string]interface{}{
+				"attrs": nil,
+				"child": map[string]interface{}{
+					"attrs": nil,
+					"items": nil,
+					"...":   "[1 more entries]",
+				},
+				"...": "[2 more entries]",
+			},
+			"...": "[2 more entries]",
+		}))
+	})
+
+	It("should not split multi-byte characters", func() {
+		a := newAttachment(0, "héllo", FormatLimits{MaxStringLength: 2}, nil)
+		Expect(string(a.Value)).To(Equal(`"h...[truncated 5 bytes]"`))
+		a = newAttachment(0, []byte("test-data"), FormatLimits{MaxStringLength: 4}, nil)
+		Expect(string(a.Value)).To(Equal(`"dGVz...[truncated 8 bytes]"`))
+	})
+
+	It("should keep numbers as is", func() {
+		a := newAttachment(0, []int64{1 << 62}, DefaultFormatLimits, nil)
+		Expect(string(a.Value)).To(Equal("[4611686018427387904]"))
+	})
+
+	It("should replace attachments exceeding max bytes per entry", func() {
+		attachments := newAttachments(
+			FormatLimits{MaxBytes: 20}, nil, "first", "second-attachment", 3,
+		)
+		Expect(string(attachments[0].Value)).To(Equal(`"first"`))
+		Expect(attachments[0].Truncated).To(BeFalse())
+		Expect(string(attachments[1].Value)).To(Equal(
+			`"...[19 bytes exceed max bytes per entry]"`,
+		))
+		Expect(attachments[1].Truncated).To(BeTrue())
+		Expect(attachments[2].Truncated).To(BeTrue())
+	})
+
+	It("should reject negative limits", func() {
+		_, err := InitWithOptions(
+			context.Background(), "testsvc", WithFormatLimits(FormatLimits{MaxDepth: -1}),
+		)
+		Expect(err).To(Equal(&ConfigError{"FormatLimits", "MaxDepth cannot be negative"}))
+
+		l, err := InitWithOptions(context.Background(), "testsvc")
+		Expect(err).ToNot(HaveOccurred())
+		err = l.SetFormatLimits(FormatLimits{MaxBytes: -1})
+		Expect(err).To(HaveOccurred())
+		Expect(l.(*logger).limits).To(Equal(DefaultFormatLimits))
+		Expect(l.SetFormatLimits(FormatLimits{MaxDepth: 2})).To(Succeed())
+		Expect(l.(*logger).limits).To(Equal(FormatLimits{MaxDepth: 2}))
+	})
+})
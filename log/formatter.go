@@ -0,0 +1,232 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Formatter renders a Record for writing to a destination.
+type Formatter interface {
+	Format(r *Record) ([]byte, error)
+}
+
+// FormatterFunc allows using ordinary functions as Formatter.
+type FormatterFunc func(r *Record) ([]byte, error)
+
+// Format calls f(r).
+func (f FormatterFunc) Format(r *Record) ([]byte, error) {
+	return f(r)
+}
+
+// NewFormatter returns the built-in Formatter with provided name. Valid names
+// are: "debug", "description", "text", "json", "logfmt" and "color".
+// The "json" Formatter includes the Record's time.
+func NewFormatter(name string) (Formatter, error) {
+	switch name {
+	case "debug":
+		return DebugFormatter{}, nil
+	case "description":
+		return DescriptionFormatter{}, nil
+	case "text":
+		return TextFormatter{}, nil
+	case "json":
+		return JSONFormatter{Time: true}, nil
+	case "logfmt":
+		return LogfmtFormatter{}, nil
+	case "color":
+		return TextFormatter{Color: true}, nil
+	}
+	return nil, errors.Errorf(
+		"unknown format %s, valid formats are: "+
+			"debug, description, text, json, logfmt and color",
+		name,
+	)
+}
+
+// JSONFormatter renders the log-entry, with its attachments,
+// as a single line of JSON.
+type JSONFormatter struct {
+	// Time adds the Record's time, in RFC 3339 format, as "time".
+	Time bool
+}
+
+// timedEntry is a log-entry with its time, as rendered by JSONFormatter.
+type timedEntry struct {
+	Time string `json:"time"`
+	entryJSON
+}
+
+// Format renders the log-entry as a single line of JSON.
+func (f JSONFormatter) Format(r *Record) ([]byte, error) {
+	var entry interface{} = newEntryJSON(r)
+	if f.Time {
+		entry = timedEntry{
+			Time:      r.Time.Format(time.RFC3339Nano),
+			entryJSON: newEntryJSON(r),
+		}
+	}
+	ml, err := json.Marshal(entry)
+	if err != nil {
+		err = errors.Wrap(err, "Error marshalling log-entry")
+		return nil, err
+	}
+	return append(ml, '\n'), nil
+}
+
+// DescriptionFormatter renders just the log-description.
+type DescriptionFormatter struct{}
+
+// Format renders the log-description followed by a newline.
+func (DescriptionFormatter) Format(r *Record) ([]byte, error) {
+	desc := r.Entry.Description
+	if !strings.HasSuffix(desc, "\n") {
+		desc += "\n"
+	}
+	return []byte(desc), nil
+}
+
+// DebugFormatter renders the entries logged at DEBUG level in a banner with
+// the caller, and the attachments on separate lines. Other entries are rendered
+// as by DescriptionFormatter. This is the default for Logger's output.
+type DebugFormatter struct{}
+
+// Format renders the banner followed by a newline.
+func (DebugFormatter) Format(r *Record) ([]byte, error) {
+	if r.Caller == "" {
+		return DescriptionFormatter{}.Format(r)
+	}
+
+	desc := strings.TrimSuffix(r.Entry.Description, "\n")
+	banner := fmt.Sprintf("%s: ===> %s\n========================", r.Caller, desc)
+	if len(r.Attachments) == 0 {
+		return []byte(banner + "\n"), nil
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\n%s %s\n", r.Time.Format("2006/01/02 15:04:05"), banner)
+	for _, a := range r.Attachments {
+		buf.WriteString("--------------\n")
+		fmt.Fprintf(buf, "==> Data %d: %s", a.Index, a.Type)
+		switch {
+		case a.Truncated && a.Length > 0:
+			fmt.Fprintf(buf, " (length: %d, truncated)", a.Length)
+		case a.Truncated:
+			buf.WriteString(" (truncated)")
+		}
+		fmt.Fprintf(buf, ":\n%s\n", a.Value)
+		if a.Error != "" {
+			fmt.Fprintf(buf, "Error marshalling data: %s\n", a.Error)
+		}
+	}
+	buf.WriteString("--------------\n========================\n")
+	return buf.Bytes(), nil
+}
+
+// textTimeFormat is the default TimeFormat of TextFormatter.
+const textTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// levelColors are the ANSI escape-codes for coloring levels in TextFormatter.
+var levelColors = map[string]string{
+	"DEBUG": "\x1b[36m",
+	"INFO":  "\x1b[32m",
+	"ERROR": "\x1b[31m",
+}
+
+// TextFormatter renders the log-entry as human-readable text, such as
+// "2018-10-18T10:00:00.000Z ERROR testsvc create errorCode=500: description".
+// The Action and ErrorCode are omitted if not set.
+type TextFormatter struct {
+	// TimeFormat is the layout for the Record's time, as in time.Format.
+	// Default is RFC 3339 with milliseconds.
+	TimeFormat string
+	// Color colors the level using ANSI escape-codes, for terminals.
+	Color bool
+}
+
+// Format renders the log-entry as a line of text. Multi-line descriptions,
+// such as of DEBUG level, are kept as is.
+func (f TextFormatter) Format(r *Record) ([]byte, error) {
+	timeFormat := f.TimeFormat
+	if timeFormat == "" {
+		timeFormat = textTimeFormat
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(r.Time.Format(timeFormat))
+	buf.WriteByte(' ')
+	level := r.Entry.Level
+	if color, ok := levelColors[level]; ok && f.Color {
+		level = color + level + "\x1b[0m"
+	}
+	buf.WriteString(level)
+	// Align the levels
+	if n := len(r.Entry.Level); n < 5 {
+		buf.WriteString(strings.Repeat(" ", 5-n))
+	}
+
+	buf.WriteByte(' ')
+	buf.WriteString(r.Entry.ServiceName)
+	if r.Entry.Action != "" {
+		buf.WriteByte(' ')
+		buf.WriteString(r.Entry.Action)
+	}
+	if r.Entry.ErrorCode != 0 {
+		buf.WriteString(" errorCode=")
+		buf.WriteString(strconv.Itoa(r.Entry.ErrorCode))
+	}
+	buf.WriteString(": ")
+	buf.WriteString(strings.TrimSuffix(r.Entry.Description, "\n"))
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// LogfmtFormatter renders the log-entry as logfmt key-value pairs, such as
+// `time=2018-10-18T10:00:00Z level=INFO serviceName=testsvc description="a b"`.
+// The keys are same as in JSONFormatter, and unset Action and ErrorCode are omitted.
+type LogfmtFormatter struct{}
+
+// Format renders the log-entry as a single line of logfmt.
+func (LogfmtFormatter) Format(r *Record) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	writePair := func(key string, value string) {
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(logfmtValue(value))
+	}
+
+	writePair("time", r.Time.Format(time.RFC3339Nano))
+	writePair("level", r.Entry.Level)
+	writePair("serviceName", r.Entry.ServiceName)
+	if r.Entry.Action != "" {
+		writePair("action", r.Entry.Action)
+	}
+	if r.Entry.ErrorCode != 0 {
+		writePair("errorCode", strconv.Itoa(r.Entry.ErrorCode))
+	}
+	writePair("description", strings.TrimSuffix(r.Entry.Description, "\n"))
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// logfmtValue quotes the value if it is empty, or contains spaces, quotes,
+// equal-signs or control-characters, so every entry stays on a single line.
+func logfmtValue(value string) string {
+	if value == "" {
+		return `""`
+	}
+	for _, c := range value {
+		if c <= ' ' || c == '=' || c == '"' || c == 0x7f {
+			return strconv.Quote(value)
+		}
+	}
+	return value
+}
@@ -0,0 +1,145 @@
+package log
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/TerrexTech/go-common-models/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Formatter", func() {
+	record := &Record{
+		Entry: model.LogEntry{
+			Action:      "test-action",
+			Description: "test description\n",
+			ErrorCode:   4,
+			Level:       "INFO",
+			ServiceName: "testsvc",
+		},
+		Time: time.Date(2018, 10, 18, 10, 20, 30, 400000000, time.UTC),
+	}
+
+	It("should render text", func() {
+		out, err := TextFormatter{}.Format(record)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(out)).To(Equal(
+			"2018-10-18T10:20:30.400Z INFO  testsvc test-action errorCode=4: " +
+				"test description\n",
+		))
+
+		out, err = TextFormatter{TimeFormat: time.Kitchen}.Format(&Record{
+			Entry: model.LogEntry{
+				Description: "multi\nline",
+				Level:       "ERROR",
+				ServiceName: "testsvc",
+			},
+			Time: record.Time,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(out)).To(Equal("10:20AM ERROR testsvc: multi\nline\n"))
+	})
+
+	It("should color levels in text", func() {
+		out, err := TextFormatter{Color: true}.Format(record)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(out)).To(HavePrefix(
+			"2018-10-18T10:20:30.400Z \x1b[32mINFO\x1b[0m  testsvc",
+		))
+	})
+
+	It("should render logfmt", func() {
+		out, err := LogfmtFormatter{}.Format(record)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(out)).To(Equal(
+			"time=2018-10-18T10:20:30.4Z level=INFO serviceName=testsvc " +
+				"action=test-action errorCode=4 description=\"test description\"\n",
+		))
+
+		out, err = LogfmtFormatter{}.Format(&Record{
+			Entry: model.LogEntry{Description: "a=\"b\"\nc", Level: "DEBUG"},
+			Time:  record.Time,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(out)).To(Equal(
+			`time=2018-10-18T10:20:30.4Z level=DEBUG serviceName="" ` +
+				`description="a=\"b\"\nc"` + "\n",
+		))
+	})
+
+	It("should render JSON with time", func() {
+		out, err := JSONFormatter{Time: true}.Format(record)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out).To(HaveSuffix("\n"))
+
+		var entry map[string]interface{}
+		Expect(json.Unmarshal(out, &entry)).To(Succeed())
+		Expect(entry).To(HaveKeyWithValue("time", "2018-10-18T10:20:30.4Z"))
+		Expect(entry).To(HaveKeyWithValue("serviceName", "testsvc"))
+
+		out, err = JSONFormatter{}.Format(record)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(out)).ToNot(ContainSubstring("time"))
+	})
+
+	It("should render DEBUG entries in banner", func() {
+		out, err := DebugFormatter{}.Format(record)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(out)).To(Equal("test description\n"))
+
+		out, err = DebugFormatter{}.Format(&Record{
+			Entry:  record.Entry,
+			Time:   record.Time,
+			Caller: "main.go:12",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(out)).To(Equal(
+			"main.go:12: ===> test description\n========================\n",
+		))
+
+		out, err = DebugFormatter{}.Format(&Record{
+			Entry:  record.Entry,
+			Time:   record.Time,
+			Caller: "main.go:12",
+			Attachments: []Attachment{
+				newAttachment(0, "test-data", FormatLimits{ArrayThreshold: 2}, nil),
+				newAttachment(1, []int{1, 2, 3}, FormatLimits{ArrayThreshold: 2}, nil),
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(out)).To(Equal(
+			"\n2018/10/18 10:20:30 main.go:12: ===> test description\n" +
+				"========================\n" +
+				"--------------\n" +
+				"==> Data 0: string:\n\"test-data\"\n" +
+				"--------------\n" +
+				"==> Data 1: []int (length: 3, truncated):\n" +
+				`[1,2,"...[1 more elements]"]` + "\n" +
+				"--------------\n" +
+				"========================\n",
+		))
+	})
+
+	It("should render attachments in JSON", func() {
+		out, err := JSONFormatter{}.Format(&Record{
+			Entry:       record.Entry,
+			Attachments: []Attachment{newAttachment(0, 4, DefaultFormatLimits, nil)},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(out)).To(ContainSubstring(
+			`"attachments":[{"index":0,"type":"int","value":4}]`,
+		))
+	})
+
+	It("should return built-in Formatters by name", func() {
+		names := []string{"debug", "description", "text", "json", "logfmt", "color"}
+		for _, name := range names {
+			f, err := NewFormatter(name)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f).ToNot(BeNil())
+		}
+		_, err := NewFormatter("xml")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,238 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultGELFChunkSize is the default maximum size of a UDP datagram for GELF,
+// which fits in common network MTUs.
+const DefaultGELFChunkSize = 1420
+
+// gelfMaxChunks is the maximum number of chunks for a GELF message.
+const gelfMaxChunks = 128
+
+// gelfChunkHeaderSize is the size of magic-bytes, message-id, sequence-number
+// and sequence-count in each chunk.
+const gelfChunkHeaderSize = 12
+
+// GELFConfig configures the GELFTransport.
+type GELFConfig struct {
+	// Network is either "udp" or "tcp".
+	Network string
+	// Address is the host:port of Graylog input.
+	Address string
+	// Host is the "host" field of messages. Default is os.Hostname.
+	Host string
+	// ChunkSize is the maximum UDP datagram size, after which messages are chunked.
+	// Default is DefaultGELFChunkSize.
+	ChunkSize int
+	// DisableCompression disables gzip for UDP. TCP messages are never compressed.
+	DisableCompression bool
+	// DialTimeout is the timeout for connecting to Graylog. Default is 5 seconds.
+	DialTimeout time.Duration
+	// WriteTimeout is the timeout for writing a message. Default is 5 seconds.
+	WriteTimeout time.Duration
+	// MaxReconnects is the number of reconnect-attempts when writing a message
+	// fails. Default is 1.
+	MaxReconnects int
+}
+
+// GELFTransport sends log-entries to Graylog as GELF 1.1 messages.
+// UDP messages are gzipped and chunked, and TCP messages are null-delimited.
+type GELFTransport struct {
+	config GELFConfig
+	conn   *reconnectingConn
+}
+
+// gelfMessage is a GELF 1.1 message with this package's additional fields.
+type gelfMessage struct {
+	Version      string  `json:"version"`
+	Host         string  `json:"host"`
+	ShortMessage string  `json:"short_message"`
+	FullMessage  string  `json:"full_message,omitempty"`
+	Timestamp    float64 `json:"timestamp,omitempty"`
+	Level        int     `json:"level"`
+	Action       string  `json:"_action,omitempty"`
+	ServiceName  string  `json:"_serviceName,omitempty"`
+	ErrorCode    int     `json:"_errorCode,omitempty"`
+	Attachments  string  `json:"_attachments,omitempty"`
+}
+
+// NewGELFTransport creates a Transport sending to Graylog.
+// The connection is established lazily on first write.
+func NewGELFTransport(config GELFConfig) (*GELFTransport, error) {
+	if config.Network != "udp" && config.Network != "tcp" {
+		return nil, errors.Errorf("invalid GELF network: %s", config.Network)
+	}
+	if config.Address == "" {
+		return nil, errors.New("empty GELF address provided")
+	}
+	if config.ChunkSize == 0 {
+		config.ChunkSize = DefaultGELFChunkSize
+	}
+	if config.ChunkSize <= gelfChunkHeaderSize {
+		return nil, errors.Errorf("ChunkSize must be greater than %d", gelfChunkHeaderSize)
+	}
+	if config.Host == "" {
+		config.Host, _ = os.Hostname()
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = 5 * time.Second
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = 5 * time.Second
+	}
+	if config.MaxReconnects <= 0 {
+		config.MaxReconnects = 1
+	}
+
+	return &GELFTransport{
+		config: config,
+		conn: &reconnectingConn{
+			network:       config.Network,
+			address:       config.Address,
+			dialTimeout:   config.DialTimeout,
+			writeTimeout:  config.WriteTimeout,
+			maxReconnects: config.MaxReconnects,
+		},
+	}, nil
+}
+
+// gelfMsg maps the Record to GELF message. The first line of Description is
+// the short_message, and multi-line descriptions are sent as full_message.
+// The Attachments of entries logged at DEBUG level are sent as JSON in the
+// _attachments field, and rendered by DebugFormatter as full_message.
+func (t *GELFTransport) gelfMsg(r *Record) gelfMessage {
+	desc := strings.TrimRight(r.Entry.Description, "\n")
+	short := strings.TrimSpace(strings.SplitN(desc, "\n", 2)[0])
+	if short == "" {
+		short = "-"
+	}
+	full := ""
+	if strings.Contains(desc, "\n") {
+		full = desc
+	}
+	if r.Caller != "" && len(r.Attachments) > 0 {
+		if debug, err := (DebugFormatter{}).Format(r); err == nil {
+			full = strings.TrimSpace(string(debug))
+		}
+	}
+
+	severity, ok := syslogSeverity[r.Entry.Level]
+	if !ok {
+		severity = syslogSeverity["INFO"]
+	}
+
+	msg := gelfMessage{
+		Version:      "1.1",
+		Host:         t.config.Host,
+		ShortMessage: short,
+		FullMessage:  full,
+		Level:        severity,
+		Action:       r.Entry.Action,
+		ServiceName:  r.Entry.ServiceName,
+		ErrorCode:    r.Entry.ErrorCode,
+		Attachments:  attachmentsJSON(r.Attachments),
+	}
+	if !r.Time.IsZero() {
+		msg.Timestamp = float64(r.Time.UnixNano()/int64(time.Millisecond)) / 1000
+	}
+	return msg
+}
+
+// Write sends the Record as GELF message.
+func (t *GELFTransport) Write(r *Record) error {
+	payload, err := json.Marshal(t.gelfMsg(r))
+	if err != nil {
+		err = errors.Wrap(err, "Error marshalling GELF message")
+		return err
+	}
+
+	if t.config.Network == "tcp" {
+		err = t.conn.write(append(payload, 0))
+	} else {
+		err = t.writeUDP(payload)
+	}
+	if err != nil {
+		err = errors.Wrap(err, "Error writing GELF message")
+	}
+	return err
+}
+
+// writeUDP compresses the payload and sends it in one or more chunks.
+func (t *GELFTransport) writeUDP(payload []byte) error {
+	if !t.config.DisableCompression {
+		buf := &bytes.Buffer{}
+		gw := gzip.NewWriter(buf)
+		_, err := gw.Write(payload)
+		if err == nil {
+			err = gw.Close()
+		}
+		if err != nil {
+			err = errors.Wrap(err, "Error compressing GELF message")
+			return err
+		}
+		payload = buf.Bytes()
+	}
+
+	chunks, err := gelfChunks(payload, t.config.ChunkSize)
+	if err != nil {
+		return err
+	}
+	for _, c := range chunks {
+		err = t.conn.write(c)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gelfChunks splits the payload into GELF chunks if it exceeds chunkSize.
+func gelfChunks(payload []byte, chunkSize int) ([][]byte, error) {
+	if len(payload) <= chunkSize {
+		return [][]byte{payload}, nil
+	}
+
+	dataSize := chunkSize - gelfChunkHeaderSize
+	count := (len(payload) + dataSize - 1) / dataSize
+	if count > gelfMaxChunks {
+		return nil, errors.Errorf(
+			"GELF message of %d bytes exceeds %d chunks", len(payload), gelfMaxChunks,
+		)
+	}
+
+	msgID := make([]byte, 8)
+	_, err := rand.Read(msgID)
+	if err != nil {
+		err = errors.Wrap(err, "Error generating GELF message-id")
+		return nil, err
+	}
+
+	chunks := make([][]byte, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * dataSize
+		if end > len(payload) {
+			end = len(payload)
+		}
+		chunk := make([]byte, 0, chunkSize)
+		chunk = append(chunk, 0x1e, 0x0f)
+		chunk = append(chunk, msgID...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunks[i] = append(chunk, payload[i*dataSize:end]...)
+	}
+	return chunks, nil
+}
+
+// Close closes the connection to Graylog.
+func (t *GELFTransport) Close() error {
+	return t.conn.close()
+}
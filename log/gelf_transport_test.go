@@ -0,0 +1,200 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/TerrexTech/go-common-models/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GELFTransport", func() {
+	var record *Record
+
+	BeforeEach(func() {
+		record = &Record{
+			Entry: model.LogEntry{
+				Action:      "test-action",
+				Description: "test.go:12: ===> test-description\n=====\ntest-data\n",
+				ErrorCode:   4,
+				Level:       "DEBUG",
+				ServiceName: "testsvc",
+			},
+			Time: time.Unix(1541795750, 250*int64(time.Millisecond)),
+		}
+	})
+
+	// readUDPMessage reads datagrams until a complete GELF message is received,
+	// and returns the decoded message.
+	readUDPMessage := func(conn net.PacketConn) map[string]interface{} {
+		chunks := map[byte][]byte{}
+		var payload []byte
+		buf := make([]byte, 65536)
+		for payload == nil {
+			err := conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			Expect(err).ToNot(HaveOccurred())
+			n, _, err := conn.ReadFrom(buf)
+			Expect(err).ToNot(HaveOccurred())
+			b := append([]byte{}, buf[:n]...)
+
+			if b[0] != 0x1e || b[1] != 0x0f {
+				payload = b
+				break
+			}
+			chunks[b[10]] = b[12:]
+			if len(chunks) == int(b[11]) {
+				for i := 0; i < len(chunks); i++ {
+					payload = append(payload, chunks[byte(i)]...)
+				}
+			}
+		}
+
+		gr, err := gzip.NewReader(bytes.NewReader(payload))
+		Expect(err).ToNot(HaveOccurred())
+		payload, err = ioutil.ReadAll(gr)
+		Expect(err).ToNot(HaveOccurred())
+
+		msg := map[string]interface{}{}
+		err = json.Unmarshal(payload, &msg)
+		Expect(err).ToNot(HaveOccurred())
+		return msg
+	}
+
+	It("should map entries to GELF messages", func() {
+		t, err := NewGELFTransport(GELFConfig{
+			Network: "udp",
+			Address: "127.0.0.1:12201",
+			Host:    "test-host",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		msg := t.gelfMsg(record)
+		Expect(msg).To(Equal(gelfMessage{
+			Version:      "1.1",
+			Host:         "test-host",
+			ShortMessage: "test.go:12: ===> test-description",
+			FullMessage:  "test.go:12: ===> test-description\n=====\ntest-data",
+			Timestamp:    1541795750.25,
+			Level:        7,
+			Action:       "test-action",
+			ServiceName:  "testsvc",
+			ErrorCode:    4,
+		}))
+	})
+
+	It("should send the attachments at DEBUG level", func() {
+		t, err := NewGELFTransport(GELFConfig{
+			Network: "udp",
+			Address: "127.0.0.1:12201",
+			Host:    "test-host",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		record.Entry.Description = "test-description"
+		record.Caller = "test.go:12"
+		record.Attachments = []Attachment{
+			{Type: "string", Value: json.RawMessage(`"test-data"`)},
+		}
+		msg := t.gelfMsg(record)
+		Expect(msg.ShortMessage).To(Equal("test-description"))
+		Expect(msg.FullMessage).To(ContainSubstring("test.go:12: ===> test-description"))
+		Expect(msg.FullMessage).To(ContainSubstring("==> Data 0: string:\n\"test-data\""))
+		Expect(msg.Attachments).To(Equal(
+			`[{"index":0,"type":"string","value":"test-data"}]`,
+		))
+	})
+
+	It("should send gzipped messages over UDP", func() {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		t, err := NewGELFTransport(GELFConfig{
+			Network: "udp",
+			Address: conn.LocalAddr().String(),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer t.Close()
+
+		Expect(t.Write(record)).To(Succeed())
+		msg := readUDPMessage(conn)
+		Expect(msg["short_message"]).To(Equal("test.go:12: ===> test-description"))
+		Expect(msg["_errorCode"]).To(BeEquivalentTo(4))
+	})
+
+	It("should chunk large messages over UDP", func() {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		t, err := NewGELFTransport(GELFConfig{
+			Network:   "udp",
+			Address:   conn.LocalAddr().String(),
+			ChunkSize: 50,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer t.Close()
+
+		// Random-ish data that does not compress too well
+		desc := ""
+		for i := 0; i < 50; i++ {
+			desc += time.Duration(i * 7919).String()
+		}
+		record.Entry.Description = desc
+		Expect(t.Write(record)).To(Succeed())
+		msg := readUDPMessage(conn)
+		Expect(msg["short_message"]).To(Equal(desc))
+	})
+
+	It("should return error if message exceeds maximum chunks", func() {
+		_, err := gelfChunks(make([]byte, 129*10), 22)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should send null-delimited messages over TCP", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer listener.Close()
+
+		msgChan := make(chan string, 10)
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			for {
+				msg, err := reader.ReadString(0)
+				if err != nil {
+					return
+				}
+				msgChan <- strings.TrimSuffix(msg, "\x00")
+			}
+		}()
+
+		t, err := NewGELFTransport(GELFConfig{
+			Network: "tcp",
+			Address: listener.Addr().String(),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer t.Close()
+
+		Expect(t.Write(record)).To(Succeed())
+		Expect(t.Write(record)).To(Succeed())
+		for i := 0; i < 2; i++ {
+			var msg string
+			Eventually(msgChan).Should(Receive(&msg))
+			m := map[string]interface{}{}
+			Expect(json.Unmarshal([]byte(msg), &m)).To(Succeed())
+			Expect(m["_action"]).To(Equal("test-action"))
+		}
+	})
+})
@@ -0,0 +1,252 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RetryConfig configures retries of failed HTTP requests.
+// Requests are retried on network errors, 5xx and 429 responses. When the
+// Transport is closed, the remaining entries are retried until ShutdownTimeout,
+// so Close does not wait for every backoff.
+type RetryConfig struct {
+	// MaxRetries is the number of retries after the first attempt.
+	// Default is 3. Set to a negative value to disable retries.
+	MaxRetries int
+	// MinBackoff is the wait before first retry, which doubles for every
+	// subsequent retry. The Retry-After header, if present, takes precedence.
+	// Default is 500 milliseconds.
+	MinBackoff time.Duration
+	// MaxBackoff is the maximum wait between retries. Default is 30 seconds.
+	MaxBackoff time.Duration
+	// ShutdownTimeout is the maximum time for which the remaining entries are
+	// retried when the Transport is closed. Default is 10 seconds.
+	ShutdownTimeout time.Duration
+}
+
+// withDefaults returns RetryConfig with unset values set to defaults.
+func (c RetryConfig) withDefaults() RetryConfig {
+	if c.MaxRetries == 0 {
+		c.MaxRetries = 3
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = 500 * time.Millisecond
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 30 * time.Second
+	}
+	if c.ShutdownTimeout <= 0 {
+		c.ShutdownTimeout = 10 * time.Second
+	}
+	return c
+}
+
+// backoff returns the wait before the retry following provided attempt,
+// starting at zero.
+func (c RetryConfig) backoff(attempt int) time.Duration {
+	wait := c.MinBackoff
+	for i := 0; i < attempt && wait < c.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > c.MaxBackoff {
+		wait = c.MaxBackoff
+	}
+	return wait
+}
+
+// httpSender POSTs payloads to a URL, retrying as per RetryConfig.
+type httpSender struct {
+	client  *http.Client
+	url     string
+	headers map[string]string
+	gzip    bool
+	retry   RetryConfig
+	// closing is closed by close, which stops waiting for retries.
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+// wait waits for the backoff before a retry, and returns false if the
+// sender is closed meanwhile.
+func (s *httpSender) wait(backoff time.Duration) bool {
+	select {
+	case <-time.After(backoff):
+		return true
+	case <-s.closing:
+		return false
+	}
+}
+
+// close stops waiting for retries, so the failing requests return early.
+func (s *httpSender) close() {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+}
+
+// closeAfter runs flush, which sends the remaining entries, and then closes
+// the sender. Failing requests are retried until ShutdownTimeout.
+func (s *httpSender) closeAfter(flush func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		flush()
+	}()
+
+	timer := time.NewTimer(s.retry.ShutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+	}
+	s.close()
+	<-done
+}
+
+// redactURL removes the userinfo and the values of query-parameters from
+// rawURL, since these can contain credentials.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return redactedValue
+	}
+	u.User = nil
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		for i, p := range params {
+			if j := strings.Index(p, "="); j >= 0 {
+				params[i] = p[:j+1] + "***"
+			}
+		}
+		u.RawQuery = strings.Join(params, "&")
+	}
+	return u.String()
+}
+
+// send POSTs the body, gzipping it if enabled.
+func (s *httpSender) send(body []byte, contentType string) error {
+	_, err := s.sendForResponse(body, contentType)
+	return err
+}
+
+// sendForResponse POSTs the body like send, and returns the response-body
+// of the successful request.
+func (s *httpSender) sendForResponse(body []byte, contentType string) ([]byte, error) {
+	if s.gzip {
+		buf := &bytes.Buffer{}
+		gw := gzip.NewWriter(buf)
+		_, err := gw.Write(body)
+		if err == nil {
+			err = gw.Close()
+		}
+		if err != nil {
+			err = errors.Wrap(err, "Error compressing request-body")
+			return nil, err
+		}
+		body = buf.Bytes()
+	}
+
+	for attempt := 0; ; attempt++ {
+		respBody, wait, err := s.post(body, contentType)
+		if err == nil {
+			return respBody, nil
+		}
+		if wait < 0 || attempt >= s.retry.MaxRetries {
+			return nil, err
+		}
+
+		if wait == 0 {
+			wait = s.retry.backoff(attempt)
+		}
+		if wait > s.retry.MaxBackoff {
+			wait = s.retry.MaxBackoff
+		}
+		if !s.wait(wait) {
+			err = errors.Wrap(err, "sender closed before retrying")
+			return nil, err
+		}
+	}
+}
+
+// post makes a single request and returns the response-body.
+// On failure, it returns the wait before retrying, which is zero if the
+// default backoff applies, or negative if the request must not be retried.
+func (s *httpSender) post(body []byte, contentType string) ([]byte, time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		err = errors.Wrap(err, "Error creating request")
+		return nil, -1, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		err = errors.Wrap(err, "Error sending request")
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		// The request succeeded, so it isn't retried even if the body
+		// is unreadable, which would duplicate the entries
+		respBody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			err = errors.Wrapf(
+				err, "Error reading response of request with status %d", resp.StatusCode,
+			)
+			log.Println(err)
+		}
+		return respBody, 0, nil
+	}
+
+	respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = errors.Errorf(
+		"request failed with status %d: %s", resp.StatusCode, string(respBody),
+	)
+	if !isRetryableStatus(resp.StatusCode) {
+		return nil, -1, err
+	}
+	return nil, parseRetryAfter(resp.Header.Get("Retry-After")), err
+}
+
+// isRetryableStatus checks if a request failing with status-code can be retried.
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// parseRetryAfter parses Retry-After header in either delay-seconds or
+// HTTP-date format. Zero is returned if header is missing or invalid.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
@@ -0,0 +1,128 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HTTPConfig configures the HTTPTransport.
+type HTTPConfig struct {
+	// URL to which the batches are POSTed.
+	URL string
+	// Headers are added to every request, such as for authorization.
+	Headers map[string]string
+	// Gzip compresses the request-body.
+	Gzip bool
+	// BatchSize is the maximum number of entries per request. Default is 100.
+	BatchSize int
+	// FlushInterval is the interval after which incomplete batches are sent.
+	// Default is 1 second.
+	FlushInterval time.Duration
+	// MaxConcurrency is the maximum number of requests in flight. Default is 2.
+	MaxConcurrency int
+	// Timeout is the timeout for a single request. Default is 10 seconds.
+	// This is ignored if Client is provided.
+	Timeout time.Duration
+	// Client is the HTTP client to use. A new client is created if nil.
+	Client *http.Client
+	// Retry configures retries of failed requests.
+	Retry RetryConfig
+}
+
+// HTTPTransport POSTs batches of log-entries as JSON array to a URL.
+type HTTPTransport struct {
+	sender *httpSender
+	batch  *batchSender
+}
+
+// NewHTTPTransport creates a Transport sending batches of entries to config.URL.
+func NewHTTPTransport(config HTTPConfig) (*HTTPTransport, error) {
+	sender, bc, err := newHTTPSender(config)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &HTTPTransport{
+		sender: sender,
+	}
+	t.batch = newBatchSender(redactURL(config.URL), bc, t.send)
+	return t, nil
+}
+
+// newHTTPSender validates the HTTPConfig and creates the sender and batch-settings
+// as per it.
+func newHTTPSender(config HTTPConfig) (*httpSender, batchConfig, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, batchConfig{}, errors.Errorf("invalid URL provided: %s", config.URL)
+	}
+	if config.BatchSize < 0 || config.MaxConcurrency < 0 {
+		return nil, batchConfig{}, errors.New(
+			"BatchSize and MaxConcurrency cannot be negative",
+		)
+	}
+
+	bc := batchConfig{
+		size:        config.BatchSize,
+		interval:    config.FlushInterval,
+		concurrency: config.MaxConcurrency,
+	}
+	if bc.size == 0 {
+		bc.size = 100
+	}
+	if bc.interval <= 0 {
+		bc.interval = time.Second
+	}
+	if bc.concurrency == 0 {
+		bc.concurrency = 2
+	}
+
+	client := config.Client
+	if client == nil {
+		timeout := config.Timeout
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+		client = &http.Client{
+			Timeout: timeout,
+		}
+	}
+
+	return &httpSender{
+		client:  client,
+		url:     config.URL,
+		headers: config.Headers,
+		gzip:    config.Gzip,
+		retry:   config.Retry.withDefaults(),
+		closing: make(chan struct{}),
+	}, bc, nil
+}
+
+func (t *HTTPTransport) send(records []*Record) error {
+	entries := make([]entryJSON, len(records))
+	for i, r := range records {
+		entries[i] = newEntryJSON(r)
+	}
+	body, err := json.Marshal(entries)
+	if err != nil {
+		err = errors.Wrap(err, "Error marshalling log-entries")
+		return err
+	}
+	return t.sender.send(body, ContentTypeJSON)
+}
+
+// Write adds the Record to current batch.
+func (t *HTTPTransport) Write(r *Record) error {
+	t.batch.add(r)
+	return nil
+}
+
+// Close sends the remaining entries and waits for requests in flight.
+func (t *HTTPTransport) Close() error {
+	t.sender.closeAfter(t.batch.close)
+	return nil
+}
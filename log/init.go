@@ -2,11 +2,10 @@ package log
 
 import (
 	"context"
-	"encoding/json"
+	"io"
 	"log"
 	"os"
 
-	"github.com/TerrexTech/go-common-models/model"
 	"github.com/TerrexTech/go-kafkautils/kafka"
 	"github.com/pkg/errors"
 )
@@ -14,7 +13,17 @@ import (
 // LogLevelEnvVar is the environment-variable from which the log-level is read.
 const LogLevelEnvVar = "LOG_LEVEL"
 
+// LogSinkEnvVar is the environment-variable which selects where Init sends the
+// log-entries. Valid values are "kafka", which is the default, and "stdout".
+const LogSinkEnvVar = "LOG_SINK"
+
 // Init creates a new Logger for handling log-messages.
+// Options, such as WithKafkaTLS and WithKafkaSASL, are applied as in
+// InitWithOptions. A *ConfigError is returned if any argument is invalid.
+// If LOG_SINK environment variable is "stdout", the Logger writes to Stdout
+// without Kafka, and config, topic and the Kafka settings of opts are ignored,
+// while the other opts still apply. This allows the same binary to run
+// without Kafka, such as for local development.
 func Init(
 	ctx context.Context,
 	// svcName is the default ServiceName to be used
@@ -22,77 +31,105 @@ func Init(
 	svcName string,
 	config *kafka.ProducerConfig,
 	topic string,
+	opts ...Option,
 ) (Logger, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	if topic == "" {
-		return nil, errors.New("empty svcName provided")
+
+	switch sink := os.Getenv(LogSinkEnvVar); sink {
+	case "stdout":
+		opts = append([]Option{WithOutput(os.Stdout)}, opts...)
+		opts = append(opts, withoutKafka())
+		return InitWithOptions(ctx, svcName, opts...)
+	case "", "kafka":
+	default:
+		return nil, &ConfigError{
+			LogSinkEnvVar, sink + ", valid values are: kafka and stdout",
+		}
 	}
-	if config == nil {
-		return nil, errors.New("nil config provided")
+
+	opts = append([]Option{WithKafka(config), WithTopic(topic)}, opts...)
+	return InitWithOptions(ctx, svcName, opts...)
+}
+
+// InitTransport creates a new Logger which sends the log-entries to provided
+// Transport instead of Kafka, such as a FileTransport for deployments without Kafka.
+// The Transport is closed when the context is closed.
+func InitTransport(
+	ctx context.Context,
+	// svcName is the default ServiceName to be used
+	// when ServiceName is not provided in LogEntry model.
+	svcName string,
+	t Transport,
+	config TransportConfig,
+) (Logger, error) {
+	if ctx == nil {
+		ctx = context.Background()
 	}
-	if topic == "" {
-		return nil, errors.New("empty topic provided")
+	l, err := newLogger(svcName)
+	if err != nil {
+		return nil, err
 	}
-
-	producer, err := kafka.NewProducer(config)
+	err = l.AddTransport(t, config)
 	if err != nil {
-		err = errors.Wrap(err, "Error creating LogTransport-Producer")
+		err = errors.Wrap(err, "Error adding LogTransport")
 		return nil, err
 	}
+	l.closeOnDone(ctx)
+	return l, nil
+}
 
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				prodErr := errors.New("LogTransport-Producer: context closed")
-				log.Println(prodErr)
-				return
-			case err := <-producer.Errors():
-				if err != nil && err.Err != nil {
-					parsedErr := errors.Wrap(err.Err, "Error in LogTransport-Producer")
-					log.Println(parsedErr)
-					log.Println(err)
-				}
-			}
-		}
-	}()
-
-	logChan := make(chan model.LogEntry, 256)
-	closeProducer := false
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				log.Println("LogTransport: context closed")
-				closeProducer = true
-				err := producer.Close()
-				if err != nil {
-					err = errors.Wrap(err, "Error closing LogTransport-Producer")
-					log.Println(err)
-				}
-				log.Println("--> Closed log-transporter")
+// InitOutput creates a new Logger which only writes the log-entries to w,
+// without sending them to Kafka, such as for CLI tools and tests.
+// Stdout is used if w is nil. Transports can still be added using AddTransport,
+// which are closed when the context is closed.
+func InitOutput(
+	ctx context.Context,
+	// svcName is the default ServiceName to be used
+	// when ServiceName is not provided in LogEntry model.
+	svcName string,
+	w io.Writer,
+) (Logger, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	l, err := newLogger(svcName)
+	if err != nil {
+		return nil, err
+	}
+	if w != nil {
+		l.output = w
+	}
+	l.closeOnDone(ctx)
+	return l, nil
+}
 
-			case l := <-logChan:
-				ml, err := json.Marshal(l)
-				if err != nil {
-					err = errors.Wrap(err, "Error marshalling log-entry")
-					log.Println(err)
-				}
-				msg := kafka.CreateMessage(topic, ml)
-				if !closeProducer {
-					producer.Input() <- msg
-				}
-			}
-		}
-	}()
+// newLogger creates a logger, with default settings, without Transports.
+func newLogger(svcName string) (*logger, error) {
+	if svcName == "" {
+		return nil, errors.New("empty svcName provided")
+	}
 
-	return &logger{
-		arrThreshold: 15,
-		logChan:      (chan<- model.LogEntry)(logChan),
+	l := &logger{
+		limits:       DefaultFormatLimits,
+		redactor:     &redactor{},
+		transports:   &fanout{},
 		enableOutput: true,
 		output:       os.Stdout,
+		formatter:    DebugFormatter{},
 		svcName:      svcName,
-	}, nil
+		keyStrategy:  NoKey,
+	}
+	return l, nil
+}
+
+// closeOnDone closes the logger's Transports when the context is closed.
+func (l *logger) closeOnDone(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		log.Println("LogTransport: context closed")
+		l.transports.close()
+		log.Println("--> Closed log-transporter")
+	}()
 }
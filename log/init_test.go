@@ -0,0 +1,102 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"os"
+
+	"github.com/TerrexTech/go-common-models/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("InitOutput", func() {
+	BeforeEach(func() {
+		err := os.Setenv(LogLevelEnvVar, "DEBUG")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		err := os.Unsetenv(LogSinkEnvVar)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should write entries only to provided writer", func() {
+		buf := &bytes.Buffer{}
+		l, err := InitOutput(context.Background(), "testsvc", buf)
+		Expect(err).ToNot(HaveOccurred())
+
+		l.I(Entry{Description: "test-info"})
+		Expect(buf.String()).To(ContainSubstring("test-info"))
+
+		buf.Reset()
+		l.D(Entry{Description: "test-debug"}, model.EventMeta{AggregateID: 3})
+		Expect(buf.String()).To(ContainSubstring("test-debug"))
+		Expect(buf.String()).To(ContainSubstring(`{"aggregateID":3`))
+
+		newBuf := &bytes.Buffer{}
+		l.SetOutput(newBuf)
+		l.E(Entry{Description: "test-error"})
+		Expect(newBuf.String()).To(ContainSubstring("test-error"))
+	})
+
+	It("should send entries to added transports", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		l, err := InitOutput(ctx, "testsvc", &bytes.Buffer{})
+		Expect(err).ToNot(HaveOccurred())
+
+		mock := &mockTransport{}
+		Expect(l.AddTransport(mock, TransportConfig{Name: "mock"})).To(Succeed())
+		l.E(Entry{Description: "test-error"})
+
+		cancel()
+		Eventually(func() bool {
+			mock.lock.Lock()
+			defer mock.lock.Unlock()
+			return mock.closed
+		}).Should(BeTrue())
+		Expect(mock.levels()).To(Equal([]string{"ERROR"}))
+	})
+
+	It("should return error if svcName is empty", func() {
+		_, err := InitOutput(context.Background(), "", nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should not require Kafka config in Init if stdout sink is selected", func() {
+		err := os.Setenv(LogSinkEnvVar, "stdout")
+		Expect(err).ToNot(HaveOccurred())
+
+		l, err := Init(context.Background(), "testsvc", nil, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(l.(*logger).output).To(Equal(os.Stdout))
+	})
+
+	It("should apply the options other than Kafka in Init with stdout sink", func() {
+		err := os.Setenv(LogSinkEnvVar, "stdout")
+		Expect(err).ToNot(HaveOccurred())
+
+		l, err := Init(
+			context.Background(), "testsvc", nil, "",
+			WithFormatter(LogfmtFormatter{}),
+			WithKafkaTLS(KafkaTLS{CAFile: "missing.pem"}),
+			WithBufferSize(10),
+			WithLevel("ERROR"),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(l.(*logger).output).To(Equal(os.Stdout))
+		Expect(l.(*logger).formatter).To(Equal(LogfmtFormatter{}))
+		Expect(l.(*logger).level).To(Equal("ERROR"))
+		Expect(l.(*logger).transports.dispatchers).To(BeEmpty())
+	})
+
+	It("should return error from Init if sink is invalid", func() {
+		err := os.Setenv(LogSinkEnvVar, "invalid")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = Init(context.Background(), "testsvc", nil, "")
+		Expect(err).To(Equal(&ConfigError{
+			LogSinkEnvVar, "invalid, valid values are: kafka and stdout",
+		}))
+	})
+})
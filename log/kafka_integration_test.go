@@ -0,0 +1,203 @@
+//go:build integration
+// +build integration
+
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/TerrexTech/uuuid"
+
+	"github.com/Shopify/sarama"
+	"github.com/TerrexTech/go-commonutils/commonutil"
+	"github.com/TerrexTech/go-kafkautils/kafka"
+	"github.com/joho/godotenv"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+// Log-message handler for testing
+type msgHandler struct {
+	msgCallback func(*sarama.ConsumerMessage) bool
+}
+
+func (*msgHandler) Setup(sarama.ConsumerGroupSession) error {
+	log.Println("Initializing Kafka MsgHandler")
+	return nil
+}
+
+func (*msgHandler) Cleanup(sarama.ConsumerGroupSession) error {
+	log.Println("Closing Kafka MsgHandler")
+	return nil
+}
+
+func (m *msgHandler) ConsumeClaim(
+	session sarama.ConsumerGroupSession,
+	claim sarama.ConsumerGroupClaim,
+) error {
+	if m.msgCallback == nil {
+		return errors.New("msgCallback cannot be nil")
+	}
+	for msg := range claim.Messages() {
+		session.MarkMessage(msg, "")
+
+		val := m.msgCallback(msg)
+		if val {
+			return nil
+		}
+	}
+	return errors.New("required value not found")
+}
+
+var _ = Describe("LogSink", func() {
+	var kafkaBrokers []string
+
+	BeforeSuite(func() {
+		log.Println("Reading environment file")
+		err := godotenv.Load("../test.env")
+		if err != nil {
+			err = errors.Wrap(err,
+				".env file not found, env-vars will be read as set in environment",
+			)
+			log.Println(err)
+		}
+
+		missingVar, err := commonutil.ValidateEnv(
+			"KAFKA_BROKERS",
+			"KAFKA_LOG_CONSUMER_GROUP",
+			"KAFKA_LOG_PRODUCER_TOPIC",
+		)
+		if err != nil {
+			err = errors.Wrapf(err, `Env-var "%s" is required, but is not set`, missingVar)
+			log.Fatalln(err)
+		}
+
+		kafkaBrokersStr := os.Getenv("KAFKA_BROKERS")
+		kafkaBrokers = *commonutil.ParseHosts(kafkaBrokersStr)
+	})
+
+	Describe("test log-production", func() {
+		var (
+			logger Logger
+
+			consumer *kafka.Consumer
+			topic    string
+		)
+
+		BeforeEach(func() {
+			cGroup := os.Getenv("KAFKA_LOG_CONSUMER_GROUP")
+			topic = os.Getenv("KAFKA_LOG_PRODUCER_TOPIC")
+
+			var err error
+
+			prodConfig := &kafka.ProducerConfig{
+				KafkaBrokers: kafkaBrokers,
+			}
+			ctx := context.Background()
+			logger, err = Init(ctx, "testsvc", prodConfig, topic)
+			Expect(err).ToNot(HaveOccurred())
+
+			consumer, err = kafka.NewConsumer(&kafka.ConsumerConfig{
+				GroupName:    cGroup,
+				KafkaBrokers: kafkaBrokers,
+				Topics:       []string{topic},
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			err := consumer.Close()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should produce logs", func(done Done) {
+			go func() {
+				for err := range consumer.Errors() {
+					defer GinkgoRecover()
+					Expect(err).ToNot(HaveOccurred())
+				}
+			}()
+
+			uuid, err := uuuid.NewV4()
+			Expect(err).ToNot(HaveOccurred())
+			testLog := Entry{
+				Description: "test-description",
+				ErrorCode:   0,
+				Action:      uuid.String(),
+				ServiceName: "testsvc",
+			}
+			logger.I(testLog)
+
+			msgCallback := func(msg *sarama.ConsumerMessage) bool {
+				defer GinkgoRecover()
+				log.Println("A Response was received on response channel")
+
+				l := &Entry{}
+				err := json.Unmarshal(msg.Value, l)
+				Expect(err).ToNot(HaveOccurred())
+
+				if l.Action == testLog.Action {
+					log.Println("The response matches")
+					close(done)
+					return true
+				}
+				return false
+			}
+
+			handler := &msgHandler{msgCallback}
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+			consumer.Consume(ctx, handler)
+		}, 20)
+
+		It("should use default service-name if none was provided", func(done Done) {
+			go func() {
+				for err := range consumer.Errors() {
+					defer GinkgoRecover()
+					Expect(err).ToNot(HaveOccurred())
+				}
+			}()
+
+			testLog := Entry{
+				Description: "test-log",
+				ErrorCode:   0,
+				Action:      "test-eventaction",
+			}
+			logger.I(testLog)
+
+			msgCallback := func(msg *sarama.ConsumerMessage) bool {
+				defer GinkgoRecover()
+				log.Println("A Response was received on response channel")
+
+				l := &Entry{}
+				err := json.Unmarshal(msg.Value, l)
+				Expect(err).ToNot(HaveOccurred())
+
+				if l.ServiceName == "testsvc" {
+					log.Println("The response matches")
+					close(done)
+					return true
+				}
+				return false
+			}
+
+			handler := &msgHandler{msgCallback}
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+			consumer.Consume(ctx, handler)
+		})
+	})
+
+	It("should use a default context when nil context is provided", func() {
+		prodConfig := &kafka.ProducerConfig{
+			KafkaBrokers: kafkaBrokers,
+		}
+		_, err := Init(context.Background(), "testsvc", prodConfig, "test-topic")
+		Expect(err).ToNot(HaveOccurred())
+	})
+})
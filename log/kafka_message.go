@@ -0,0 +1,129 @@
+package log
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+
+	"github.com/Shopify/sarama"
+	"github.com/TerrexTech/go-common-models/model"
+	"github.com/TerrexTech/go-kafkautils/kafka"
+	"github.com/TerrexTech/uuuid"
+	"github.com/pkg/errors"
+)
+
+// LogSchemaVersion is the version of the log-entry schema produced by this package.
+// This is sent as "schemaVersion" header with every Kafka message.
+const LogSchemaVersion = "1"
+
+// ContentTypeJSON is the content-type of the Kafka message-values produced by this package.
+const ContentTypeJSON = "application/json"
+
+// Kafka record-header keys added to every log-message.
+const (
+	HeaderLevel         = "level"
+	HeaderServiceName   = "serviceName"
+	HeaderAction        = "action"
+	HeaderSchemaVersion = "schemaVersion"
+	HeaderContentType   = "contentType"
+)
+
+// KeyStrategy determines the Kafka message-key for a log-entry.
+// Entries with same key are produced to same partition, and hence stay ordered.
+// The data is the additional data provided to the log-level function.
+// An empty key results in a keyless message.
+type KeyStrategy func(entry model.LogEntry, data ...interface{}) string
+
+// NoKey produces keyless messages, so the entries are spread across partitions.
+// This is the default.
+func NoKey(model.LogEntry, ...interface{}) string {
+	return ""
+}
+
+// KeyByServiceName uses the entry's ServiceName as message-key.
+func KeyByServiceName(entry model.LogEntry, _ ...interface{}) string {
+	return entry.ServiceName
+}
+
+// KeyByCorrelationID uses the CorrelationID of first Command, Document, Event,
+// or EventStoreQuery from data as message-key.
+// The message is keyless if no such data is provided.
+func KeyByCorrelationID(_ model.LogEntry, data ...interface{}) string {
+	for _, d := range data {
+		var cid uuuid.UUID
+		switch t := derefData(d).(type) {
+		case model.Command:
+			cid = t.CorrelationID
+		case model.Document:
+			cid = t.CorrelationID
+		case model.Event:
+			cid = t.CorrelationID
+		case model.EventStoreQuery:
+			cid = t.CorrelationID
+		default:
+			continue
+		}
+		if cid != (uuuid.UUID{}) {
+			return cid.String()
+		}
+	}
+	return ""
+}
+
+// KeyByAggregateID uses the AggregateID of first Event, EventMeta,
+// or EventStoreQuery from data as message-key.
+// The message is keyless if no such data is provided.
+func KeyByAggregateID(_ model.LogEntry, data ...interface{}) string {
+	for _, d := range data {
+		var aid int8
+		switch t := derefData(d).(type) {
+		case model.Event:
+			aid = t.AggregateID
+		case model.EventMeta:
+			aid = t.AggregateID
+		case model.EventStoreQuery:
+			aid = t.AggregateID
+		default:
+			continue
+		}
+		if aid != 0 {
+			return strconv.Itoa(int(aid))
+		}
+	}
+	return ""
+}
+
+// derefData returns the value pointed to by d if d is a non-nil pointer.
+func derefData(d interface{}) interface{} {
+	if d == nil {
+		return nil
+	}
+	v := reflect.ValueOf(d)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		return v.Elem().Interface()
+	}
+	return d
+}
+
+// createLogMessage creates the Kafka message for provided Record,
+// including the key and record-headers.
+func createLogMessage(topic string, r *Record) (*sarama.ProducerMessage, error) {
+	ml, err := json.Marshal(newEntryJSON(r))
+	if err != nil {
+		err = errors.Wrap(err, "Error marshalling log-entry")
+		return nil, err
+	}
+
+	pm := kafka.CreateKeyMessage(topic, r.Key, ml)
+	pm.Headers = []sarama.RecordHeader{
+		{Key: []byte(HeaderLevel), Value: []byte(r.Entry.Level)},
+		{Key: []byte(HeaderServiceName), Value: []byte(r.Entry.ServiceName)},
+		{Key: []byte(HeaderAction), Value: []byte(r.Entry.Action)},
+		{Key: []byte(HeaderSchemaVersion), Value: []byte(LogSchemaVersion)},
+		{Key: []byte(HeaderContentType), Value: []byte(ContentTypeJSON)},
+	}
+	return pm, nil
+}
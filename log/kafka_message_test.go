@@ -0,0 +1,93 @@
+package log
+
+import (
+	"encoding/json"
+
+	"github.com/TerrexTech/go-common-models/model"
+	"github.com/TerrexTech/uuuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("KafkaMessage", func() {
+	var entry model.LogEntry
+
+	BeforeEach(func() {
+		entry = model.LogEntry{
+			Action:      "test-action",
+			Description: "test-description",
+			Level:       "INFO",
+			ServiceName: "testsvc",
+		}
+	})
+
+	Describe("KeyStrategy", func() {
+		It("should produce keyless messages by default", func() {
+			Expect(NoKey(entry)).To(BeEmpty())
+		})
+
+		It("should use service-name as key", func() {
+			Expect(KeyByServiceName(entry)).To(Equal("testsvc"))
+		})
+
+		It("should use correlation-id from first model with correlation-id", func() {
+			cid, err := uuuid.NewV4()
+			Expect(err).ToNot(HaveOccurred())
+
+			key := KeyByCorrelationID(
+				entry,
+				"test-data",
+				&model.Event{},
+				model.Command{CorrelationID: cid},
+			)
+			Expect(key).To(Equal(cid.String()))
+		})
+
+		It("should use aggregate-id from first model with aggregate-id", func() {
+			key := KeyByAggregateID(
+				entry,
+				4,
+				model.EventMeta{},
+				&model.Event{AggregateID: 3},
+			)
+			Expect(key).To(Equal("3"))
+		})
+
+		It("should produce keyless messages if no id is found in data", func() {
+			Expect(KeyByCorrelationID(entry, "test", nil)).To(BeEmpty())
+			Expect(KeyByAggregateID(entry, "test", nil)).To(BeEmpty())
+		})
+	})
+
+	It("should create message with key and headers", func() {
+		msg, err := createLogMessage("test-topic", &Record{
+			Entry: entry,
+			Key:   "test-key",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(msg.Topic).To(Equal("test-topic"))
+
+		key, err := msg.Key.Encode()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(key)).To(Equal("test-key"))
+
+		value, err := msg.Value.Encode()
+		Expect(err).ToNot(HaveOccurred())
+		e := model.LogEntry{}
+		err = json.Unmarshal(value, &e)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(e).To(Equal(entry))
+
+		headers := map[string]string{}
+		for _, h := range msg.Headers {
+			headers[string(h.Key)] = string(h.Value)
+		}
+		Expect(headers).To(Equal(map[string]string{
+			HeaderLevel:         "INFO",
+			HeaderServiceName:   "testsvc",
+			HeaderAction:        "test-action",
+			HeaderSchemaVersion: LogSchemaVersion,
+			HeaderContentType:   ContentTypeJSON,
+		}))
+	})
+})
@@ -0,0 +1,165 @@
+package log
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+)
+
+// KafkaTLS configures TLS for connecting to Kafka brokers.
+type KafkaTLS struct {
+	// CAFile is the PEM file of CA-certificates for verifying brokers.
+	// System's CAs are used if not set.
+	CAFile string `json:"caFile,omitempty" yaml:"caFile,omitempty"`
+	// CertFile is the PEM file of client-certificate, for brokers requiring
+	// client-authentication. Requires KeyFile.
+	CertFile string `json:"certFile,omitempty" yaml:"certFile,omitempty"`
+	// KeyFile is the PEM file of client-certificate's private key.
+	KeyFile string `json:"keyFile,omitempty" yaml:"keyFile,omitempty"`
+	// ServerName is used to verify the brokers' certificates, if it differs
+	// from the brokers' addresses, such as when connecting through a proxy.
+	ServerName string `json:"serverName,omitempty" yaml:"serverName,omitempty"`
+	// InsecureSkipVerify disables verification of brokers' certificates.
+	// This should only be used for testing.
+	InsecureSkipVerify bool `json:"insecureSkipVerify" yaml:"insecureSkipVerify"`
+}
+
+// apply loads the certificates, and enables TLS in config.
+func (t *KafkaTLS) apply(config *sarama.Config) error {
+	tlsConfig, err := t.tlsConfig()
+	if err != nil {
+		return err
+	}
+	config.Net.TLS.Enable = true
+	config.Net.TLS.Config = tlsConfig
+	return nil
+}
+
+// tlsConfig loads the certificates and creates the tls.Config.
+func (t *KafkaTLS) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		pool, err := loadCertPool(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+
+	switch {
+	case t.CertFile != "" && t.KeyFile != "":
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			err = errors.Wrap(err, "Error loading client key-pair")
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	case t.CertFile != "":
+		return nil, errors.New("KeyFile is required when CertFile is set")
+	case t.KeyFile != "":
+		return nil, errors.New("CertFile is required when KeyFile is set")
+	}
+	return config, nil
+}
+
+// loadCertPool loads the PEM-encoded certificates from file.
+func loadCertPool(file string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(file)
+	if err != nil {
+		err = errors.Wrap(err, "Error reading CA-file")
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf("no PEM certificates found in %s", file)
+	}
+	return pool, nil
+}
+
+// KafkaSASL configures SASL authentication with Kafka brokers.
+type KafkaSASL struct {
+	// Mechanism is one of: PLAIN, SCRAM-SHA-256 and SCRAM-SHA-512.
+	// Default is PLAIN.
+	Mechanism string `json:"mechanism,omitempty" yaml:"mechanism,omitempty"`
+	Username  string `json:"username" yaml:"username"`
+	// Password or PasswordFile, which contains the password, is required.
+	Password     string `json:"password,omitempty" yaml:"password,omitempty"`
+	PasswordFile string `json:"passwordFile,omitempty" yaml:"passwordFile,omitempty"`
+}
+
+// validate checks the settings, without reading the PasswordFile.
+func (s *KafkaSASL) validate() error {
+	switch s.Mechanism {
+	case "", sarama.SASLTypePlaintext,
+		sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512:
+	default:
+		return errors.Errorf(
+			"invalid Mechanism %s, valid mechanisms are: "+
+				"PLAIN, SCRAM-SHA-256 and SCRAM-SHA-512",
+			s.Mechanism,
+		)
+	}
+	if s.Username == "" {
+		return errors.New("empty Username provided")
+	}
+	if (s.Password == "") == (s.PasswordFile == "") {
+		return errors.New("exactly one of Password and PasswordFile must be set")
+	}
+	return nil
+}
+
+// apply reads the PasswordFile, and enables SASL in config.
+func (s *KafkaSASL) apply(config *sarama.Config) error {
+	err := s.validate()
+	if err != nil {
+		return err
+	}
+
+	password := s.Password
+	if s.PasswordFile != "" {
+		content, err := ioutil.ReadFile(s.PasswordFile)
+		if err != nil {
+			err = errors.Wrap(err, "Error reading PasswordFile")
+			return err
+		}
+		password = strings.TrimSpace(string(content))
+		if password == "" {
+			return errors.New("PasswordFile is empty")
+		}
+	}
+
+	mechanism := s.Mechanism
+	if mechanism == "" {
+		mechanism = sarama.SASLTypePlaintext
+	}
+	config.Net.SASL.SCRAMClientGeneratorFunc = nil
+	if mechanism != sarama.SASLTypePlaintext {
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return newSCRAMClient(mechanism)
+		}
+	}
+
+	config.Net.SASL.Enable = true
+	config.Net.SASL.Handshake = true
+	config.Net.SASL.Mechanism = sarama.SASLMechanism(mechanism)
+	config.Net.SASL.User = s.Username
+	config.Net.SASL.Password = password
+	return nil
+}
+
+// copySaramaConfig returns a copy of config, or the default config if nil.
+func copySaramaConfig(config *sarama.Config) *sarama.Config {
+	if config == nil {
+		return newSaramaConfig()
+	}
+	c := *config
+	return &c
+}
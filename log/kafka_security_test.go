@@ -0,0 +1,323 @@
+package log
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Shopify/sarama"
+	"github.com/TerrexTech/go-kafkautils/kafka"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// writeTestCertFiles writes a certificate from newTestCert, and its key,
+// as PEM files in dir.
+func writeTestCertFiles(dir string) (certFile string, keyFile string) {
+	cert, _ := newTestCert()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+
+	certPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: cert.Certificate[0],
+	})
+	Expect(ioutil.WriteFile(certFile, certPEM, 0600)).To(Succeed())
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	Expect(err).ToNot(HaveOccurred())
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	Expect(ioutil.WriteFile(keyFile, keyPEM, 0600)).To(Succeed())
+	return certFile, keyFile
+}
+
+var _ = Describe("Kafka security", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "kafkasecurity")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	Describe("SCRAM", func() {
+		// Test-vector from RFC 7677
+		const (
+			clientNonce = "rOprNGfwEbeRWgbNEkqO"
+			serverFirst = "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0," +
+				"s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+			clientFinal = "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0," +
+				"p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="
+			serverFinal = "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="
+		)
+
+		var client *scramClient
+
+		BeforeEach(func() {
+			client = newSCRAMClient(sarama.SASLTypeSCRAMSHA256)
+			Expect(client.Begin("user", "pencil", "")).To(Succeed())
+			client.nonce = clientNonce
+		})
+
+		It("should complete SCRAM-SHA-256 exchange", func() {
+			msg, err := client.Step("")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(msg).To(Equal("n,,n=user,r=" + clientNonce))
+			Expect(client.Done()).To(BeFalse())
+
+			msg, err = client.Step(serverFirst)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(msg).To(Equal(clientFinal))
+			Expect(client.Done()).To(BeFalse())
+
+			msg, err = client.Step(serverFinal)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(msg).To(BeEmpty())
+			Expect(client.Done()).To(BeTrue())
+		})
+
+		It("should reject invalid server messages", func() {
+			_, err := client.Step("")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = client.Step("r=otherNonce,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+			Expect(err).To(HaveOccurred())
+
+			Expect(client.Begin("user", "pencil", "")).To(Succeed())
+			client.nonce = clientNonce
+			_, err = client.Step("")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = client.Step(serverFirst)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = client.Step("v=aW52YWxpZA==")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should report server errors", func() {
+			_, err := client.Step("")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = client.Step(serverFirst)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = client.Step("e=invalid-proof")
+			Expect(err).To(MatchError(ContainSubstring("invalid-proof")))
+		})
+
+		It("should use SHA-512 for SCRAM-SHA-512", func() {
+			Expect(newSCRAMClient(sarama.SASLTypeSCRAMSHA512).hash().Size()).To(Equal(64))
+		})
+
+		It("should escape username", func() {
+			Expect(client.Begin("a=b,c", "pencil", "")).To(Succeed())
+			client.nonce = clientNonce
+			msg, err := client.Step("")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(msg).To(Equal("n,,n=a=3Db=2Cc,r=" + clientNonce))
+		})
+
+		It("should derive PBKDF2 key", func() {
+			// Test-vector from RFC 6070
+			key := pbkdf2Key(sha1.New, []byte("password"), []byte("salt"), 4096)
+			Expect(hex.EncodeToString(key)).To(Equal(
+				"4b007901b765489abead49d926f721d065a429c1",
+			))
+		})
+	})
+
+	Describe("KafkaTLS", func() {
+		// handshake connects to a TLS server requiring client-certificates, and
+		// returns the number of certificates presented by client.
+		handshake := func(config *tls.Config) (int, error) {
+			cert, _ := newTestCert()
+			listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				ClientAuth:   tls.RequireAnyClientCert,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			defer listener.Close()
+
+			certCount := make(chan int, 1)
+			go func() {
+				defer GinkgoRecover()
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				tlsConn := conn.(*tls.Conn)
+				if tlsConn.Handshake() == nil {
+					certCount <- len(tlsConn.ConnectionState().PeerCertificates)
+				}
+			}()
+
+			conn, err := tls.Dial("tcp", listener.Addr().String(), config)
+			if err != nil {
+				return 0, err
+			}
+			defer conn.Close()
+			return <-certCount, nil
+		}
+
+		It("should verify server and present client-certificate", func() {
+			// Server uses a different certificate, so it is verified using the
+			// client's certificate as CA, which is also self-signed.
+			certFile, keyFile := writeTestCertFiles(dir)
+			config, err := (&KafkaTLS{
+				CAFile:   certFile,
+				CertFile: certFile,
+				KeyFile:  keyFile,
+			}).tlsConfig()
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = handshake(config)
+			Expect(err).To(HaveOccurred())
+
+			config.InsecureSkipVerify = true
+			certCount, err := handshake(config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(certCount).To(Equal(1))
+		})
+
+		It("should verify server using ServerName", func() {
+			cert, pool := newTestCert()
+			listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+				Certificates: []tls.Certificate{cert},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			defer listener.Close()
+			go func() {
+				for {
+					conn, err := listener.Accept()
+					if err != nil {
+						return
+					}
+					conn.(*tls.Conn).Handshake()
+					conn.Close()
+				}
+			}()
+
+			for serverName, valid := range map[string]bool{
+				"localhost":   true,
+				"example.com": false,
+			} {
+				config, err := (&KafkaTLS{ServerName: serverName}).tlsConfig()
+				Expect(err).ToNot(HaveOccurred())
+				config.RootCAs = pool
+				conn, err := tls.Dial("tcp", listener.Addr().String(), config)
+				if valid {
+					Expect(err).ToNot(HaveOccurred())
+					conn.Close()
+				} else {
+					Expect(err).To(HaveOccurred())
+				}
+			}
+		})
+
+		It("should return error for invalid files", func() {
+			certFile, _ := writeTestCertFiles(dir)
+			cases := []KafkaTLS{
+				{CAFile: filepath.Join(dir, "missing.pem")},
+				{CertFile: certFile},
+				{CertFile: certFile, KeyFile: certFile},
+			}
+			for _, c := range cases {
+				_, err := c.tlsConfig()
+				Expect(err).To(HaveOccurred())
+			}
+		})
+	})
+
+	Describe("KafkaSASL", func() {
+		It("should enable SCRAM with password from file", func() {
+			passwordFile := filepath.Join(dir, "password")
+			Expect(ioutil.WriteFile(passwordFile, []byte("secret\n"), 0600)).To(Succeed())
+
+			config := sarama.NewConfig()
+			err := (&KafkaSASL{
+				Mechanism:    sarama.SASLTypeSCRAMSHA512,
+				Username:     "user",
+				PasswordFile: passwordFile,
+			}).apply(config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.Net.SASL.Enable).To(BeTrue())
+			Expect(config.Net.SASL.Mechanism).To(Equal(sarama.SASLMechanism("SCRAM-SHA-512")))
+			Expect(config.Net.SASL.User).To(Equal("user"))
+			Expect(config.Net.SASL.Password).To(Equal("secret"))
+			Expect(config.Net.SASL.SCRAMClientGeneratorFunc().(*scramClient).hash().Size()).
+				To(Equal(64))
+			Expect(config.Validate()).To(Succeed())
+		})
+
+		It("should default to PLAIN", func() {
+			config := sarama.NewConfig()
+			err := (&KafkaSASL{Username: "user", Password: "pass"}).apply(config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.Net.SASL.Mechanism).To(Equal(sarama.SASLMechanism("PLAIN")))
+			Expect(config.Net.SASL.SCRAMClientGeneratorFunc).To(BeNil())
+		})
+
+		It("should return error for invalid settings", func() {
+			cases := []KafkaSASL{
+				{Mechanism: "GSSAPI", Username: "user", Password: "pass"},
+				{Password: "pass"},
+				{Username: "user"},
+				{Username: "user", Password: "pass", PasswordFile: "password"},
+				{Username: "user", PasswordFile: filepath.Join(dir, "missing")},
+			}
+			for _, c := range cases {
+				Expect(c.apply(sarama.NewConfig())).ToNot(Succeed())
+			}
+		})
+	})
+
+	Describe("Init", func() {
+		config := &kafka.ProducerConfig{KafkaBrokers: []string{"kafka:9092"}}
+
+		It("should return ConfigError for invalid security settings", func() {
+			cases := []struct {
+				field string
+				opts  []Option
+			}{
+				{"KafkaTLS", []Option{WithKafkaTLS(KafkaTLS{})}},
+				{"KafkaSASL", []Option{WithKafkaSASL(KafkaSASL{Username: "user"})}},
+				{"KafkaTLS", []Option{
+					WithKafka(config),
+					WithTopic("test-topic"),
+					WithKafkaTLS(KafkaTLS{CAFile: filepath.Join(dir, "missing.pem")}),
+				}},
+				{"KafkaSASL", []Option{
+					WithKafka(config),
+					WithTopic("test-topic"),
+					WithKafkaSASL(KafkaSASL{
+						Username:     "user",
+						PasswordFile: filepath.Join(dir, "missing"),
+					}),
+				}},
+			}
+			for _, c := range cases {
+				_, err := InitWithOptions(context.Background(), "testsvc", c.opts...)
+				Expect(err).To(BeAssignableToTypeOf(&ConfigError{}))
+				Expect(err.(*ConfigError).Field).To(Equal(c.field))
+			}
+
+			_, err := Init(
+				context.Background(), "testsvc", config, "test-topic",
+				WithKafkaSASL(KafkaSASL{Username: "user", Mechanism: "invalid"}),
+			)
+			Expect(err).To(Equal(&ConfigError{
+				"KafkaSASL",
+				"invalid Mechanism invalid, valid mechanisms are: " +
+					"PLAIN, SCRAM-SHA-256 and SCRAM-SHA-512",
+			}))
+			Expect(config.SaramaConfig).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,120 @@
+package log
+
+import (
+	"log"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/TerrexTech/go-kafkautils/kafka"
+	"github.com/pkg/errors"
+)
+
+// KafkaTransport produces log-entries to a Kafka topic, to be consumed by go-logsink.
+type KafkaTransport struct {
+	producer sarama.AsyncProducer
+	topic    string
+
+	// closing is closed by Close, which unblocks the pending Writes.
+	closing   chan struct{}
+	closeOnce sync.Once
+	// Writes hold closeLock's read-lock while sending, so Close can wait for
+	// them before closing producer's input.
+	closeLock sync.RWMutex
+	closed    bool
+	errDone   chan struct{}
+}
+
+// NewKafkaTransport creates a Transport producing log-entries to provided topic.
+func NewKafkaTransport(config *kafka.ProducerConfig, topic string) (*KafkaTransport, error) {
+	if config == nil {
+		return nil, errors.New("nil config provided")
+	}
+	if topic == "" {
+		return nil, errors.New("empty topic provided")
+	}
+
+	producer, err := kafka.NewProducer(config)
+	if err != nil {
+		err = errors.Wrap(err, "Error creating LogTransport-Producer")
+		return nil, err
+	}
+	return NewKafkaTransportFromProducer(producer, topic)
+}
+
+// NewKafkaTransportFromProducer creates a Transport producing log-entries to
+// provided topic using an existing producer, such as a producer shared with
+// the service, or a mock producer in tests. The producer is closed with Transport.
+func NewKafkaTransportFromProducer(
+	producer sarama.AsyncProducer,
+	topic string,
+) (*KafkaTransport, error) {
+	if producer == nil {
+		return nil, errors.New("nil producer provided")
+	}
+	if topic == "" {
+		return nil, errors.New("empty topic provided")
+	}
+
+	t := &KafkaTransport{
+		producer: producer,
+		topic:    topic,
+		closing:  make(chan struct{}),
+		errDone:  make(chan struct{}),
+	}
+
+	go func() {
+		defer close(t.errDone)
+		for err := range producer.Errors() {
+			if err != nil && err.Err != nil {
+				parsedErr := errors.Wrap(err.Err, "Error in LogTransport-Producer")
+				log.Println(parsedErr)
+				log.Println(err)
+			}
+		}
+	}()
+
+	return t, nil
+}
+
+// Write produces the Record to Kafka. Writes blocked on a full producer
+// return an error when the Transport is closed.
+func (t *KafkaTransport) Write(r *Record) error {
+	msg, err := createLogMessage(t.topic, r)
+	if err != nil {
+		return err
+	}
+
+	t.closeLock.RLock()
+	defer t.closeLock.RUnlock()
+	if t.closed {
+		return errors.New("LogTransport-Producer is closed")
+	}
+	select {
+	case t.producer.Input() <- msg:
+		return nil
+	case <-t.closing:
+		return errors.New("LogTransport-Producer is closed")
+	}
+}
+
+// Close flushes the pending messages and closes the producer,
+// returning the errors of messages which failed while flushing.
+func (t *KafkaTransport) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.closing)
+	})
+	t.closeLock.Lock()
+	if t.closed {
+		t.closeLock.Unlock()
+		return nil
+	}
+	t.closed = true
+	t.closeLock.Unlock()
+
+	err := t.producer.Close()
+	<-t.errDone
+	if err != nil {
+		err = errors.Wrap(err, "Error closing LogTransport-Producer")
+	}
+	return err
+}
@@ -0,0 +1,688 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TerrexTech/go-common-models/model"
+	"github.com/TerrexTech/go-kafkautils/kafka"
+	"github.com/pkg/errors"
+	fsnotify "gopkg.in/fsnotify.v1"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// LogConfig configures the Logger from a YAML or JSON file, see WithConfigFile.
+// Unset settings keep the values provided to InitWithOptions.
+type LogConfig struct {
+	// Level is the minimum level of logged entries.
+	// Valid levels are: DEBUG, INFO, ERROR and NONE.
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+	// Overrides set the level for entries of specific services or actions.
+	// The first matching override is used.
+	Overrides []LevelOverride `json:"overrides,omitempty" yaml:"overrides,omitempty"`
+	// Output is where the logs are written besides the Transports.
+	// Valid values are "stdout", "stderr" and "none".
+	Output string `json:"output,omitempty" yaml:"output,omitempty"`
+	// Format is the format of logs written to output, see NewFormatter.
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// Action is the default Action for logging if none is set in Entry.
+	Action string `json:"action,omitempty" yaml:"action,omitempty"`
+	// ArrayThreshold is the array-length after which arrays are trimmed.
+	ArrayThreshold int `json:"arrayThreshold,omitempty" yaml:"arrayThreshold,omitempty"`
+	// Redaction are the rules for masking sensitive data, which replace
+	// the ones provided to InitWithOptions.
+	Redaction []RedactionRule `json:"redaction,omitempty" yaml:"redaction,omitempty"`
+	// Transports are the destinations to which the log-entries are sent,
+	// in addition to the ones provided to InitWithOptions.
+	Transports []TransportSpec `json:"transports,omitempty" yaml:"transports,omitempty"`
+}
+
+// LevelOverride sets the level for entries matching its ServiceName and Action.
+// Empty ServiceName or Action matches any value.
+type LevelOverride struct {
+	ServiceName string `json:"serviceName,omitempty" yaml:"serviceName,omitempty"`
+	Action      string `json:"action,omitempty" yaml:"action,omitempty"`
+	Level       string `json:"level" yaml:"level"`
+}
+
+func (o LevelOverride) matches(entry model.LogEntry) bool {
+	return (o.ServiceName == "" || o.ServiceName == entry.ServiceName) &&
+		(o.Action == "" || o.Action == entry.Action)
+}
+
+// RouteRule selects the entries sent to a Transport.
+// Empty lists match any value.
+type RouteRule struct {
+	ServiceNames []string `json:"serviceNames,omitempty" yaml:"serviceNames,omitempty"`
+	Actions      []string `json:"actions,omitempty" yaml:"actions,omitempty"`
+}
+
+func (r *RouteRule) matches(rec *Record) bool {
+	return matchesAny(r.ServiceNames, rec.Entry.ServiceName) &&
+		matchesAny(r.Actions, rec.Entry.Action)
+}
+
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// TransportSpec declares a Transport in LogConfig.
+// The settings are used as per Type, and the rest are ignored.
+type TransportSpec struct {
+	// Name identifies the Transport, and must be unique.
+	Name string `json:"name" yaml:"name"`
+	// Type is one of: file, http, elasticsearch, loki, otlp, syslog, gelf,
+	// fluent, agent and kafka.
+	Type string `json:"type" yaml:"type"`
+	// Level, BufferSize are as in TransportConfig.
+	Level      string `json:"level,omitempty" yaml:"level,omitempty"`
+	BufferSize int    `json:"bufferSize,omitempty" yaml:"bufferSize,omitempty"`
+	// Route, if set, selects the entries sent to Transport.
+	Route *RouteRule `json:"route,omitempty" yaml:"route,omitempty"`
+
+	// Settings for "file" Transport, as in FileConfig.
+	// Format is as in NewFormatter. Default is "json".
+	Path       string `json:"path,omitempty" yaml:"path,omitempty"`
+	Format     string `json:"format,omitempty" yaml:"format,omitempty"`
+	MaxSize    int64  `json:"maxSize,omitempty" yaml:"maxSize,omitempty"`
+	MaxAge     string `json:"maxAge,omitempty" yaml:"maxAge,omitempty"`
+	MaxBackups int    `json:"maxBackups,omitempty" yaml:"maxBackups,omitempty"`
+	Compress   bool   `json:"compress,omitempty" yaml:"compress,omitempty"`
+
+	// Settings for "http", "elasticsearch", "loki" and "otlp" Transports,
+	// as in HTTPConfig.
+	URL       string            `json:"url,omitempty" yaml:"url,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Gzip      bool              `json:"gzip,omitempty" yaml:"gzip,omitempty"`
+	BatchSize int               `json:"batchSize,omitempty" yaml:"batchSize,omitempty"`
+
+	// FlushInterval and MaxAge are durations, such as "5s".
+	FlushInterval string `json:"flushInterval,omitempty" yaml:"flushInterval,omitempty"`
+
+	// Settings for "syslog", "gelf" and "fluent" Transports.
+	Network string `json:"network,omitempty" yaml:"network,omitempty"`
+	Address string `json:"address,omitempty" yaml:"address,omitempty"`
+
+	// SocketPath is the log-agent's socket for "agent" Transport.
+	SocketPath string `json:"socketPath,omitempty" yaml:"socketPath,omitempty"`
+
+	// Settings for "kafka" Transport.
+	Brokers []string   `json:"brokers,omitempty" yaml:"brokers,omitempty"`
+	Topic   string     `json:"topic,omitempty" yaml:"topic,omitempty"`
+	TLS     *KafkaTLS  `json:"tls,omitempty" yaml:"tls,omitempty"`
+	SASL    *KafkaSASL `json:"sasl,omitempty" yaml:"sasl,omitempty"`
+}
+
+// LoadLogConfig reads and validates the LogConfig from a YAML or JSON file.
+// The file is parsed as JSON if it has ".json" extension, and as YAML otherwise.
+// Unknown settings are rejected.
+func LoadLogConfig(path string) (*LogConfig, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		err = errors.Wrap(err, "Error reading LogConfig")
+		return nil, err
+	}
+	return parseLogConfig(path, content)
+}
+
+func parseLogConfig(path string, content []byte) (*LogConfig, error) {
+	config := &LogConfig{}
+	var err error
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(config)
+	} else {
+		err = yaml.UnmarshalStrict(content, config)
+	}
+	if err != nil {
+		err = errors.Wrapf(err, "Error parsing LogConfig %s", path)
+		return nil, err
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate checks the LogConfig, and returns a *ConfigError for the first
+// invalid setting. The Field is the setting's path, such as "transports[1].type".
+// Type-specific settings of Transports are validated when they are created.
+func (c *LogConfig) Validate() error {
+	if c.Level != "" && !isValidLevel(c.Level) {
+		return &ConfigError{"level", "invalid level " + c.Level}
+	}
+	for i, o := range c.Overrides {
+		if !isValidLevel(o.Level) {
+			field := fmt.Sprintf("overrides[%d].level", i)
+			return &ConfigError{field, "invalid level " + o.Level}
+		}
+	}
+	switch c.Output {
+	case "", "stdout", "stderr", "none":
+	default:
+		return &ConfigError{"output", "valid values are: stdout, stderr and none"}
+	}
+	if c.Format != "" {
+		if _, err := NewFormatter(c.Format); err != nil {
+			return &ConfigError{"format", err.Error()}
+		}
+	}
+	for i, rule := range c.Redaction {
+		if err := rule.validate(); err != nil {
+			return &ConfigError{fmt.Sprintf("redaction[%d]", i), err.Error()}
+		}
+	}
+	if c.ArrayThreshold < 0 {
+		return &ConfigError{"arrayThreshold", "cannot be negative"}
+	}
+
+	names := map[string]bool{}
+	for i, t := range c.Transports {
+		field := fmt.Sprintf("transports[%d]", i)
+		if t.Name == "" {
+			return &ConfigError{field + ".name", "required"}
+		}
+		if names[t.Name] {
+			return &ConfigError{field + ".name", "duplicate name " + t.Name}
+		}
+		names[t.Name] = true
+
+		switch t.Type {
+		case "file", "http", "elasticsearch", "loki", "otlp",
+			"syslog", "gelf", "fluent", "agent", "kafka":
+		default:
+			return &ConfigError{field + ".type", "unknown type " + t.Type}
+		}
+		if _, ok := levelRank[t.Level]; !ok && t.Level != "" {
+			return &ConfigError{field + ".level", "invalid level " + t.Level}
+		}
+		if t.BufferSize < 0 {
+			return &ConfigError{field + ".bufferSize", "cannot be negative"}
+		}
+		if t.Format != "" {
+			if _, err := NewFormatter(t.Format); err != nil {
+				return &ConfigError{field + ".format", err.Error()}
+			}
+		}
+		if _, err := parseDuration(t.MaxAge); err != nil {
+			return &ConfigError{field + ".maxAge", err.Error()}
+		}
+		if _, err := parseDuration(t.FlushInterval); err != nil {
+			return &ConfigError{field + ".flushInterval", err.Error()}
+		}
+		if t.SASL != nil {
+			if err := t.SASL.validate(); err != nil {
+				return &ConfigError{field + ".sasl", err.Error()}
+			}
+		}
+	}
+	return nil
+}
+
+func isValidLevel(level string) bool {
+	_, ok := levelRank[level]
+	return ok || level == "NONE"
+}
+
+// parseDuration parses an optional duration, such as "5s".
+func parseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// newTransport creates the Transport declared by TransportSpec.
+func (s *TransportSpec) newTransport() (Transport, error) {
+	maxAge, _ := parseDuration(s.MaxAge)
+	flushInterval, _ := parseDuration(s.FlushInterval)
+	httpConfig := HTTPConfig{
+		URL:           s.URL,
+		Headers:       s.Headers,
+		Gzip:          s.Gzip,
+		BatchSize:     s.BatchSize,
+		FlushInterval: flushInterval,
+	}
+
+	switch s.Type {
+	case "file":
+		format := s.Format
+		if format == "" {
+			format = "json"
+		}
+		formatter, err := NewFormatter(format)
+		if err != nil {
+			return nil, err
+		}
+		return NewFileTransport(FileConfig{
+			Path:       s.Path,
+			Formatter:  formatter,
+			MaxSize:    s.MaxSize,
+			MaxAge:     maxAge,
+			MaxBackups: s.MaxBackups,
+			Compress:   s.Compress,
+		})
+	case "http":
+		return NewHTTPTransport(httpConfig)
+	case "elasticsearch":
+		return NewESTransport(ESConfig{HTTPConfig: httpConfig})
+	case "loki":
+		return NewLokiTransport(LokiConfig{HTTPConfig: httpConfig})
+	case "otlp":
+		return NewOTLPTransport(OTLPConfig{HTTPConfig: httpConfig})
+	case "syslog":
+		return NewSyslogTransport(SyslogConfig{Network: s.Network, Address: s.Address})
+	case "gelf":
+		return NewGELFTransport(GELFConfig{Network: s.Network, Address: s.Address})
+	case "fluent":
+		return NewFluentTransport(FluentConfig{Network: s.Network, Address: s.Address})
+	case "agent":
+		return NewAgentTransport(AgentConfig{SocketPath: s.SocketPath})
+	case "kafka":
+		saramaConfig := newSaramaConfig()
+		if s.TLS != nil {
+			err := s.TLS.apply(saramaConfig)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if s.SASL != nil {
+			err := s.SASL.apply(saramaConfig)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return NewKafkaTransport(&kafka.ProducerConfig{
+			KafkaBrokers: s.Brokers,
+			SaramaConfig: saramaConfig,
+		}, s.Topic)
+	}
+	return nil, errors.Errorf("unknown type %s", s.Type)
+}
+
+// newDispatcher creates the Transport and its dispatcher.
+func (s *TransportSpec) newDispatcher() (*dispatcher, error) {
+	t, err := s.newTransport()
+	if err != nil {
+		return nil, err
+	}
+	d, err := newDispatcher(t, TransportConfig{
+		Name:       s.Name,
+		Level:      s.Level,
+		BufferSize: s.BufferSize,
+	})
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	if s.Route != nil {
+		d.match = s.Route.matches
+	}
+	return d, nil
+}
+
+// configTransport is a Transport created from TransportSpec.
+type configTransport struct {
+	spec TransportSpec
+	d    *dispatcher
+}
+
+// configLoader applies the LogConfig from a file to logger,
+// and reloads it when the file changes.
+type configLoader struct {
+	path string
+	l    *logger
+	// The base settings are the logger's settings before applying LogConfig,
+	// which are restored when a setting is removed from the file.
+	baseOutput       io.Writer
+	baseEnableOutput bool
+	baseFormatter    Formatter
+	baseLevel        string
+	baseAction       string
+	baseArrThreshold int
+	baseRedactor     *redactor
+
+	config     *LogConfig
+	content    []byte
+	transports map[string]*configTransport
+	// lastErr avoids logging the same reload-error repeatedly
+	lastErr string
+}
+
+func newConfigLoader(l *logger, path string) *configLoader {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return &configLoader{
+		path:             path,
+		l:                l,
+		baseOutput:       l.output,
+		baseEnableOutput: l.enableOutput,
+		baseFormatter:    l.formatter,
+		baseLevel:        l.level,
+		baseAction:       l.action,
+		baseArrThreshold: l.limits.ArrayThreshold,
+		baseRedactor:     l.redactor,
+		config:           &LogConfig{},
+		transports:       map[string]*configTransport{},
+	}
+}
+
+// load applies the LogConfig from file.
+func (c *configLoader) load() error {
+	content, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		err = errors.Wrap(err, "Error reading LogConfig")
+		return err
+	}
+	config, err := parseLogConfig(c.path, content)
+	if err != nil {
+		return err
+	}
+	err = c.apply(config)
+	if err != nil {
+		return err
+	}
+	c.content = content
+	return nil
+}
+
+// watch reloads the LogConfig when the file changes, until the context is
+// closed. The file's directory is watched, so files replaced by renaming,
+// such as by editors, are reloaded too.
+func (c *configLoader) watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		err = errors.Wrap(err, "Error creating LogConfig watcher")
+		return err
+	}
+	err = watcher.Add(filepath.Dir(c.path))
+	if err != nil {
+		watcher.Close()
+		err = errors.Wrap(err, "Error watching LogConfig directory")
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+		name := filepath.Base(c.path)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-watcher.Events:
+				// Kubernetes ConfigMaps are updated by replacing the "..data" link
+				base := filepath.Base(event.Name)
+				if base == name || base == "..data" {
+					c.reload()
+				}
+			case err := <-watcher.Errors:
+				err = errors.Wrap(err, "Error watching LogConfig")
+				log.Println(err)
+			}
+		}
+	}()
+	return nil
+}
+
+// reload applies the LogConfig if the file has changed, and logs the changed
+// settings. An invalid LogConfig is rejected, and the current one is kept.
+func (c *configLoader) reload() {
+	content, err := ioutil.ReadFile(c.path)
+	if err == nil && bytes.Equal(content, c.content) {
+		return
+	}
+
+	old := c.config
+	if err == nil {
+		var config *LogConfig
+		config, err = parseLogConfig(c.path, content)
+		if err == nil {
+			err = c.apply(config)
+		}
+	}
+	if err != nil {
+		if err.Error() != c.lastErr {
+			c.lastErr = err.Error()
+			err = errors.Wrapf(
+				err, "Error reloading LogConfig %s, keeping current config", c.path,
+			)
+			log.Println(err)
+		}
+		return
+	}
+	c.content = content
+	c.lastErr = ""
+
+	c.l.lock.RLock()
+	r := c.l.redactor
+	c.l.lock.RUnlock()
+
+	log.Printf("LogConfig: reloaded %s", c.path)
+	for _, change := range diffLogConfigs(old, c.config, r) {
+		log.Printf("LogConfig: %s", change)
+	}
+}
+
+// apply creates the Transports which were added or changed in config, and
+// closes the ones removed or changed. Only the settings changed since the
+// previously applied config are set, so the ones set using Logger's methods
+// are kept.
+func (c *configLoader) apply(config *LogConfig) error {
+	transports := map[string]*configTransport{}
+	added := []*dispatcher{}
+	for i, spec := range config.Transports {
+		ct, ok := c.transports[spec.Name]
+		if ok && reflect.DeepEqual(ct.spec, spec) {
+			transports[spec.Name] = ct
+			continue
+		}
+		d, err := spec.newDispatcher()
+		if err != nil {
+			closeDispatchers(added)
+			return &ConfigError{fmt.Sprintf("transports[%d]", i), err.Error()}
+		}
+		added = append(added, d)
+		transports[spec.Name] = &configTransport{spec, d}
+	}
+
+	removed := []*dispatcher{}
+	for name, ct := range c.transports {
+		if transports[name] != ct {
+			removed = append(removed, ct.d)
+		}
+	}
+	err := c.l.transports.swap(removed, added)
+	if err != nil {
+		return err
+	}
+	c.transports = transports
+
+	old := c.config
+	c.config = config
+
+	l := c.l
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.overrides = config.Overrides
+	if config.Level != old.Level {
+		l.level = config.Level
+		if l.level == "" {
+			l.level = c.baseLevel
+		}
+	}
+	if config.Action != old.Action {
+		l.action = config.Action
+		if l.action == "" {
+			l.action = c.baseAction
+		}
+	}
+	if config.ArrayThreshold != old.ArrayThreshold {
+		l.limits.ArrayThreshold = config.ArrayThreshold
+		if l.limits.ArrayThreshold == 0 {
+			l.limits.ArrayThreshold = c.baseArrThreshold
+		}
+	}
+	if config.Output != old.Output {
+		switch config.Output {
+		case "stdout":
+			l.enableOutput, l.output = true, os.Stdout
+		case "stderr":
+			l.enableOutput, l.output = true, os.Stderr
+		case "none":
+			l.enableOutput = false
+		default:
+			l.enableOutput, l.output = c.baseEnableOutput, c.baseOutput
+		}
+	}
+	if !reflect.DeepEqual(config.Redaction, old.Redaction) {
+		l.redactor = c.baseRedactor
+		if len(config.Redaction) > 0 {
+			// The rules are already validated
+			l.redactor, _ = newRedactor(config.Redaction)
+		}
+	}
+	if config.Format != old.Format {
+		l.formatter = c.baseFormatter
+		if config.Format != "" {
+			l.formatter, _ = NewFormatter(config.Format)
+		}
+	}
+	return nil
+}
+
+// diffLogConfigs describes the settings changed between the configs,
+// such as `level: "INFO" -> "DEBUG"`. Transports are identified by name,
+// and the values of secret settings are masked, see isSecretSetting.
+// Credentials are removed from URLs.
+func diffLogConfigs(old *LogConfig, updated *LogConfig, r *redactor) []string {
+	oldValues := flattenLogConfig(old)
+	newValues := flattenLogConfig(updated)
+
+	keys := []string{}
+	for k := range oldValues {
+		keys = append(keys, k)
+	}
+	for k := range newValues {
+		if _, ok := oldValues[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := []string{}
+	for _, k := range keys {
+		oldValue, inOld := oldValues[k]
+		newValue, inNew := newValues[k]
+		if oldValue == newValue {
+			continue
+		}
+		if isSecretSetting(k, r) {
+			oldValue, newValue = `"***"`, `"***"`
+		} else if strings.HasSuffix(k, ".url") {
+			oldValue, newValue = redactURLSetting(oldValue), redactURLSetting(newValue)
+		}
+		switch {
+		case !inOld:
+			changes = append(changes, fmt.Sprintf("%s: set to %s", k, newValue))
+		case !inNew:
+			changes = append(changes, fmt.Sprintf("%s: removed, was %s", k, oldValue))
+		default:
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", k, oldValue, newValue))
+		}
+	}
+	return changes
+}
+
+// isSecretSetting checks if the value at path k, as in flattenLogConfig,
+// is secret. These are passwords, HTTP headers such as Authorization,
+// and the settings whose name matches a Key rule of r.
+func isSecretSetting(k string, r *redactor) bool {
+	if strings.Contains(k, ".headers.") {
+		return true
+	}
+	name := k[strings.LastIndex(k, ".")+1:]
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+	if name == "password" {
+		return true
+	}
+	if r == nil {
+		return false
+	}
+	_, ok := r.keyMask(name)
+	return ok
+}
+
+// redactURLSetting removes the credentials from the URL in JSON value,
+// see redactURL.
+func redactURLSetting(value string) string {
+	var rawURL string
+	if json.Unmarshal([]byte(value), &rawURL) != nil {
+		return value
+	}
+	ml, _ := json.Marshal(redactURL(rawURL))
+	return string(ml)
+}
+
+// flattenLogConfig maps the path of every set value in config, such as
+// "transports.file.path", to its JSON value.
+func flattenLogConfig(config *LogConfig) map[string]string {
+	transports := map[string]TransportSpec{}
+	for _, t := range config.Transports {
+		transports[t.Name] = t
+	}
+	settings := *config
+	settings.Transports = nil
+
+	values := map[string]string{}
+	flattenJSON("", settings, values)
+	flattenJSON("transports", transports, values)
+	return values
+}
+
+func flattenJSON(prefix string, v interface{}, values map[string]string) {
+	// LogConfig only contains values which can be marshalled
+	ml, _ := json.Marshal(v)
+	var generic interface{}
+	json.Unmarshal(ml, &generic)
+
+	switch value := generic.(type) {
+	case map[string]interface{}:
+		for k, v := range value {
+			if prefix != "" {
+				k = prefix + "." + k
+			}
+			flattenJSON(k, v, values)
+		}
+	case []interface{}:
+		for i, v := range value {
+			flattenJSON(fmt.Sprintf("%s[%d]", prefix, i), v, values)
+		}
+	default:
+		values[prefix] = string(ml)
+	}
+}
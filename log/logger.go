@@ -3,13 +3,16 @@ package log
 import (
 	"io"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/TerrexTech/go-common-models/model"
 	"github.com/pkg/errors"
 )
 
 // Logger provides convenient handling for log-messages.
-// Additional data can be provided to log-levels and will be marshalled and added to log.
+// Additional data can be provided to log-levels, which is marshalled and sent
+// with the log-entry as Attachments at DEBUG level.
 // If the data is one of Common-Models, the included data-elements, such as
 // "Data" in Event, Document, and Command, are also attempted to be
 // parsed and converted to readable JSON before the log is produced.
@@ -22,6 +25,7 @@ type Logger interface {
 	E(entry Entry, data ...interface{})
 	// F produces ERROR logs which will discard INFO and DEBUG logs,
 	// and produce only ERROR logs. This also exits the program using os.Exit after logging.
+	// Before exiting, Transports are given a few seconds to send the queued entries.
 	F(entry Entry, data ...interface{})
 	// I produces INFO logs, which also include ERROR logs.
 	// DEBUG logs are discarded from production.
@@ -32,14 +36,33 @@ type Logger interface {
 	// EnableOutput enables writing to Output. This is the default.
 	EnableOutput()
 	// SetArrayThreshold sets threshold for array-length. Arrays exceeding this length will
-	// be trimmed. Default value is 15.
+	// be trimmed. Default value is 15. This is same as FormatLimits.ArrayThreshold.
 	SetArrayThreshold(threshold int)
+	// SetFormatLimits sets the limits for data attached at DEBUG level.
+	// Default is DefaultFormatLimits. An error is returned for invalid limits,
+	// and the current limits are kept.
+	SetFormatLimits(limits FormatLimits) error
+	// SetRedaction sets the rules for masking sensitive data in attachments
+	// and descriptions, replacing the current rules. Struct-fields tagged
+	// `log:"redact"` are always masked. An error is returned for invalid rules.
+	SetRedaction(rules ...RedactionRule) error
+	// AddTransport adds a destination to which the log-entries are sent,
+	// in addition to the Kafka topic provided to Init.
+	// Every Transport has its own queue, so a stalled Transport does not block others.
+	AddTransport(t Transport, config TransportConfig) error
+	// SetKeyStrategy sets the strategy used to determine the Kafka message-key
+	// for log-entries. Default is NoKey, which produces keyless messages.
+	SetKeyStrategy(strategy KeyStrategy)
 	// SetAction sets default Action for logging if none is set in Entry.
 	// Default is blank string.
 	SetAction(action string)
 	// SetOutput sets the output to which the logs are written.
 	// Default is Stdout.
 	SetOutput(w io.Writer)
+	// SetFormatter sets the Formatter used for writing logs to output, such as
+	// TextFormatter. See NewFormatter for built-in Formatters. Default is
+	// DebugFormatter, which writes just the descriptions, besides DEBUG level.
+	SetFormatter(f Formatter)
 }
 
 // Entry is a single log-entry.
@@ -50,12 +73,27 @@ type Entry struct {
 	ServiceName string `json:"serviceName,omitempty"`
 }
 
+// fatalCloseTimeout is the maximum time F waits for the Transports to send
+// the queued entries before exiting.
+const fatalCloseTimeout = 5 * time.Second
+
+// exit exits the program after F, replaced in tests.
+var exit = os.Exit
+
 // logger implements Logger interface
 type logger struct {
-	logChan      chan<- model.LogEntry
+	transports *fanout
+	// lock guards the settings below, which can be changed by reloading LogConfig
+	lock         sync.RWMutex
 	enableOutput bool
 	output       io.Writer
-	arrThreshold int
+	formatter    Formatter
+	limits       FormatLimits
+	redactor     *redactor
+	keyStrategy  KeyStrategy
+	// level overrides the LOG_LEVEL environment variable, if set
+	level     string
+	overrides []LevelOverride
 
 	action  string
 	svcName string
@@ -63,24 +101,80 @@ type logger struct {
 
 func (l *logger) SetArrayThreshold(threshold int) {
 	if threshold > 0 {
-		l.arrThreshold = threshold
+		l.lock.Lock()
+		l.limits.ArrayThreshold = threshold
+		l.lock.Unlock()
+	}
+}
+
+func (l *logger) SetFormatLimits(limits FormatLimits) error {
+	err := limits.validate()
+	if err != nil {
+		err = errors.Wrap(err, "Error setting FormatLimits")
+		return err
+	}
+	l.lock.Lock()
+	l.limits = limits
+	l.lock.Unlock()
+	return nil
+}
+
+func (l *logger) SetRedaction(rules ...RedactionRule) error {
+	r, err := newRedactor(rules)
+	if err != nil {
+		err = errors.Wrap(err, "Error setting redaction-rules")
+		return err
+	}
+	l.lock.Lock()
+	l.redactor = r
+	l.lock.Unlock()
+	return nil
+}
+
+func (l *logger) AddTransport(t Transport, config TransportConfig) error {
+	return l.transports.add(t, config)
+}
+
+func (l *logger) SetKeyStrategy(strategy KeyStrategy) {
+	if strategy == nil {
+		strategy = NoKey
 	}
+	l.lock.Lock()
+	l.keyStrategy = strategy
+	l.lock.Unlock()
 }
 
 func (l *logger) SetAction(action string) {
+	l.lock.Lock()
 	l.action = action
+	l.lock.Unlock()
 }
 
 func (l *logger) DisableOutput() {
+	l.lock.Lock()
 	l.enableOutput = false
+	l.lock.Unlock()
 }
 
 func (l *logger) EnableOutput() {
+	l.lock.Lock()
 	l.enableOutput = true
+	l.lock.Unlock()
 }
 
 func (l *logger) SetOutput(w io.Writer) {
+	l.lock.Lock()
 	l.output = w
+	l.lock.Unlock()
+}
+
+func (l *logger) SetFormatter(f Formatter) {
+	if f == nil {
+		f = DebugFormatter{}
+	}
+	l.lock.Lock()
+	l.formatter = f
+	l.lock.Unlock()
 }
 
 func (l *logger) D(entry Entry, data ...interface{}) {
@@ -111,7 +205,9 @@ func (l *logger) F(entry Entry, data ...interface{}) {
 		Level:       "ERROR",
 		ServiceName: entry.ServiceName,
 	}, data...)
-	os.Exit(1)
+	// Dispatching is asynchronous, so the Transports are drained before exiting
+	l.transports.closeWithin(fatalCloseTimeout)
+	exit(1)
 }
 
 func (l *logger) I(entry Entry, data ...interface{}) {
@@ -125,7 +221,26 @@ func (l *logger) I(entry Entry, data ...interface{}) {
 }
 
 func (l *logger) log(entry model.LogEntry, data ...interface{}) {
-	level := os.Getenv(LogLevelEnvVar)
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	if entry.ServiceName == "" {
+		entry.ServiceName = l.svcName
+	}
+	if entry.Action == "" {
+		entry.Action = l.action
+	}
+
+	level := l.level
+	for _, o := range l.overrides {
+		if o.matches(entry) {
+			level = o.Level
+			break
+		}
+	}
+	if level == "" {
+		level = os.Getenv(LogLevelEnvVar)
+	}
 	invalidConfig := false
 	if level != "INFO" && level != "ERROR" && level != "DEBUG" && level != "NONE" {
 		invalidConfig = true
@@ -145,24 +260,13 @@ func (l *logger) log(entry model.LogEntry, data ...interface{}) {
 		}
 	}
 
-	if entry.ServiceName == "" {
-		entry.ServiceName = l.svcName
+	builder := &RecordBuilder{
+		limits:   l.limits,
+		redactor: l.redactor,
 	}
-	if entry.Action == "" {
-		entry.Action = l.action
-	}
-
-	if level == "DEBUG" {
-		desc, err := fmtDebug(entry.Description, l.arrThreshold, data...)
-		if err != nil {
-			err = errors.Wrap(err, "Error while formatting log for Debug-level")
-			entry.Description += desc + "\n" + err.Error()
-		} else {
-			entry.Description = desc
-		}
-	}
-	entry.Description += "\n"
-
+	// Skip this and the log-level function
+	record := builder.build(entry, l.keyStrategy, level == "DEBUG", 2, data)
+	entry = record.Entry
 	if l.enableOutput {
 		if invalidConfig {
 			l.output.Write([]byte(
@@ -170,8 +274,13 @@ func (l *logger) log(entry model.LogEntry, data ...interface{}) {
 					"Valid levels are: ERROR, INFO and DEBUG. " + "INFO level will be used.\n",
 			))
 		}
-		l.output.Write([]byte(entry.Description))
+		out, err := l.formatter.Format(record)
+		if err != nil {
+			err = errors.Wrap(err, "Error formatting log-entry for output")
+			out = []byte(entry.Description + err.Error() + "\n")
+		}
+		l.output.Write(out)
 	}
 
-	l.logChan <- entry
+	l.transports.dispatch(record)
 }
@@ -0,0 +1,330 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/TerrexTech/go-common-models/model"
+	"github.com/TerrexTech/go-kafkautils/kafka"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+// mockReporter collects the expectation-failures of sarama mocks, since
+// the mocks report from their own goroutine.
+type mockReporter struct {
+	lock   sync.Mutex
+	errors []string
+}
+
+func (r *mockReporter) Errorf(format string, args ...interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *mockReporter) failures() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.errors
+}
+
+// blockingProducer is a sarama.AsyncProducer whose Input is never read.
+type blockingProducer struct {
+	sarama.AsyncProducer
+	errors chan *sarama.ProducerError
+}
+
+func (p *blockingProducer) Input() chan<- *sarama.ProducerMessage {
+	return make(chan *sarama.ProducerMessage)
+}
+
+func (p *blockingProducer) Errors() <-chan *sarama.ProducerError {
+	return p.errors
+}
+
+func (p *blockingProducer) Close() error {
+	close(p.errors)
+	return nil
+}
+
+var _ = Describe("Logger", func() {
+	var (
+		l        Logger
+		output   *bytes.Buffer
+		producer *mocks.AsyncProducer
+		reporter *mockReporter
+		cancel   context.CancelFunc
+	)
+
+	// newLogger creates a Logger producing to a mock producer, which expects
+	// the provided number of messages.
+	newLogger := func(expected int) {
+		config := sarama.NewConfig()
+		config.Producer.Return.Successes = true
+		reporter = &mockReporter{}
+		producer = mocks.NewAsyncProducer(reporter, config)
+		for i := 0; i < expected; i++ {
+			producer.ExpectInputAndSucceed()
+		}
+
+		kt, err := NewKafkaTransportFromProducer(producer, "test-topic")
+		Expect(err).ToNot(HaveOccurred())
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		l, err = InitTransport(ctx, "testsvc", kt, TransportConfig{Name: "kafka"})
+		Expect(err).ToNot(HaveOccurred())
+
+		output = &bytes.Buffer{}
+		l.SetOutput(output)
+	}
+
+	// produced closes the Logger, and returns the entries it produced.
+	produced := func() []model.LogEntry {
+		l.(*logger).transports.close()
+		entries := []model.LogEntry{}
+		for msg := range producer.Successes() {
+			Expect(msg.Topic).To(Equal("test-topic"))
+			value, err := msg.Value.Encode()
+			Expect(err).ToNot(HaveOccurred())
+			entry := model.LogEntry{}
+			err = json.Unmarshal(value, &entry)
+			Expect(err).ToNot(HaveOccurred())
+			entries = append(entries, entry)
+		}
+		Expect(reporter.failures()).To(BeEmpty())
+		return entries
+	}
+
+	descriptions := func(entries []model.LogEntry) []string {
+		descs := []string{}
+		for _, e := range entries {
+			descs = append(descs, strings.TrimSpace(e.Description))
+		}
+		return descs
+	}
+
+	AfterEach(func() {
+		if cancel != nil {
+			cancel()
+		}
+		err := os.Setenv(LogLevelEnvVar, "DEBUG")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should produce entries with default service-name and action", func() {
+		err := os.Setenv(LogLevelEnvVar, "INFO")
+		Expect(err).ToNot(HaveOccurred())
+		newLogger(2)
+
+		l.SetAction("default-action")
+		l.I(Entry{Description: "first"})
+		l.E(Entry{
+			Action:      "test-action",
+			Description: "second",
+			ErrorCode:   4,
+			ServiceName: "some-name",
+		})
+
+		Expect(produced()).To(Equal([]model.LogEntry{
+			{
+				Action:      "default-action",
+				Description: "first\n",
+				Level:       "INFO",
+				ServiceName: "testsvc",
+			},
+			{
+				Action:      "test-action",
+				Description: "second\n",
+				ErrorCode:   4,
+				Level:       "ERROR",
+				ServiceName: "some-name",
+			},
+		}))
+		Expect(output.String()).To(Equal("first\nsecond\n"))
+	})
+
+	It("should not publish DEBUG logs if INFO level is specified", func() {
+		err := os.Setenv(LogLevelEnvVar, "INFO")
+		Expect(err).ToNot(HaveOccurred())
+		newLogger(2)
+
+		l.I(Entry{Description: "info"})
+		l.E(Entry{Description: "error"})
+		l.D(Entry{Description: "debug"})
+		Expect(descriptions(produced())).To(Equal([]string{"info", "error"}))
+	})
+
+	It("should not publish DEBUG and INFO logs if ERROR level is specified", func() {
+		err := os.Setenv(LogLevelEnvVar, "ERROR")
+		Expect(err).ToNot(HaveOccurred())
+		newLogger(1)
+
+		l.I(Entry{Description: "info"})
+		l.E(Entry{Description: "error"})
+		l.D(Entry{Description: "debug"})
+		Expect(descriptions(produced())).To(Equal([]string{"error"}))
+	})
+
+	It("should not publish logs if NONE level is specified", func() {
+		err := os.Setenv(LogLevelEnvVar, "NONE")
+		Expect(err).ToNot(HaveOccurred())
+		newLogger(0)
+
+		l.I(Entry{Description: "info"})
+		l.E(Entry{Description: "error"})
+		Expect(produced()).To(BeEmpty())
+		Expect(output.String()).To(BeEmpty())
+	})
+
+	It("should use INFO level and warn if level is invalid", func() {
+		err := os.Setenv(LogLevelEnvVar, "invalid")
+		Expect(err).ToNot(HaveOccurred())
+		newLogger(1)
+
+		l.I(Entry{Description: "info"})
+		l.D(Entry{Description: "debug"})
+		Expect(descriptions(produced())).To(Equal([]string{"info"}))
+		Expect(output.String()).To(ContainSubstring(
+			LogLevelEnvVar + " environment variable missing or set to invalid value",
+		))
+	})
+
+	It("should attach data to log-entry if DEBUG level is specified", func() {
+		err := os.Setenv(LogLevelEnvVar, "DEBUG")
+		Expect(err).ToNot(HaveOccurred())
+		newLogger(1)
+
+		data := []interface{}{
+			&model.EventStoreQuery{
+				AggregateID:      1,
+				AggregateVersion: 3,
+			},
+			[]model.EventMeta{
+				{AggregateID: 2, AggregateVersion: 8},
+			},
+			"test-data",
+			4,
+		}
+		l.D(Entry{Description: "debug"}, data...)
+		l.(*logger).transports.close()
+
+		var msg *sarama.ProducerMessage
+		Expect(producer.Successes()).To(Receive(&msg))
+		value, err := msg.Value.Encode()
+		Expect(err).ToNot(HaveOccurred())
+		entry := entryJSON{}
+		Expect(json.Unmarshal(value, &entry)).To(Succeed())
+		Expect(entry.Description).To(Equal("debug\n"))
+
+		types := []string{}
+		for i, a := range entry.Attachments {
+			Expect(a.Index).To(Equal(i))
+			types = append(types, a.Type)
+		}
+		Expect(types).To(Equal([]string{
+			"model.EventStoreQuery", "[]model.EventMeta", "string", "int",
+		}))
+		Expect(string(entry.Attachments[2].Value)).To(Equal(`"test-data"`))
+
+		// Banner is written to output
+		Expect(output.String()).To(ContainSubstring("logger_test.go"))
+		Expect(output.String()).To(ContainSubstring("===> debug\n"))
+		Expect(output.String()).To(ContainSubstring(
+			"==> Data 0: model.EventStoreQuery:\n{",
+		))
+		Expect(output.String()).To(ContainSubstring(`"aggregateVersion":3`))
+		Expect(output.String()).To(ContainSubstring("==> Data 2: string:\n\"test-data\"\n"))
+	})
+
+	It("should write output using Formatter", func() {
+		err := os.Setenv(LogLevelEnvVar, "INFO")
+		Expect(err).ToNot(HaveOccurred())
+		newLogger(2)
+
+		l.SetFormatter(LogfmtFormatter{})
+		l.E(Entry{Description: "first", Action: "test-action", ErrorCode: 4})
+		l.SetFormatter(nil)
+		l.I(Entry{Description: "second"})
+
+		Expect(descriptions(produced())).To(Equal([]string{"first", "second"}))
+		Expect(output.String()).To(MatchRegexp(
+			`^time=\S+ level=ERROR serviceName=testsvc action=test-action ` +
+				"errorCode=4 description=first\nsecond\n$",
+		))
+	})
+
+	It("should produce queued entries when closed", func() {
+		newLogger(100)
+		for i := 0; i < 100; i++ {
+			l.I(Entry{Description: "test"})
+		}
+		Expect(produced()).To(HaveLen(100))
+	})
+
+	It("should continue producing after producer errors", func() {
+		err := os.Setenv(LogLevelEnvVar, "INFO")
+		Expect(err).ToNot(HaveOccurred())
+		newLogger(0)
+		producer.ExpectInputAndFail(errors.New("test-error"))
+		producer.ExpectInputAndSucceed()
+
+		l.I(Entry{Description: "failed"})
+		l.I(Entry{Description: "succeeded"})
+		Expect(descriptions(produced())).To(Equal([]string{"succeeded"}))
+	})
+
+	It("should return error when writing to closed KafkaTransport", func() {
+		producer := mocks.NewAsyncProducer(&mockReporter{}, nil)
+		kt, err := NewKafkaTransportFromProducer(producer, "test-topic")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(kt.Close()).To(Succeed())
+		Expect(kt.Write(&Record{})).To(HaveOccurred())
+	})
+
+	It("should not block Close on Writes to a full producer", func() {
+		producer := &blockingProducer{errors: make(chan *sarama.ProducerError)}
+		kt, err := NewKafkaTransportFromProducer(producer, "test-topic")
+		Expect(err).ToNot(HaveOccurred())
+
+		written := make(chan error)
+		go func() {
+			written <- kt.Write(&Record{})
+		}()
+		Consistently(written).ShouldNot(Receive())
+
+		Expect(kt.Close()).To(Succeed())
+		Eventually(written).Should(Receive(HaveOccurred()))
+		Expect(kt.Close()).To(Succeed())
+	})
+
+	Describe("Init", func() {
+		It("should return error if default svc-name is empty", func() {
+			_, err := Init(context.Background(), "", &kafka.ProducerConfig{}, "")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return error if producer-config is nil", func() {
+			_, err := Init(context.Background(), "testsvc", nil, "test-topic")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return error if producer-topic is empty", func() {
+			_, err := Init(context.Background(), "testsvc", &kafka.ProducerConfig{}, "")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return error if producer is nil", func() {
+			_, err := NewKafkaTransportFromProducer(nil, "test-topic")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
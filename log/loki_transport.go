@@ -0,0 +1,273 @@
+package log
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// lokiPushPath is the path of Loki's push API.
+const lokiPushPath = "/loki/api/v1/push"
+
+// LokiOtherValue replaces label-values exceeding the cardinality-limit.
+const LokiOtherValue = "_other"
+
+// Stream-label names used by LokiTransport.
+const (
+	LokiLabelService = "service"
+	LokiLabelLevel   = "level"
+	LokiLabelAction  = "action"
+)
+
+// LokiConfig configures the LokiTransport.
+type LokiConfig struct {
+	// HTTPConfig configures the requests and batching.
+	// URL is the Loki base-URL, such as "http://localhost:3100", to which
+	// the push-path is appended if URL has no path.
+	// MaxConcurrency is always 1, since Loki requires in-order entries per stream.
+	HTTPConfig
+	// StaticLabels are added to every stream.
+	StaticLabels map[string]string
+	// MaxLabelValues is the maximum number of distinct values for each of the
+	// service, level and action labels. Further values are replaced by
+	// LokiOtherValue, and the actual value is kept in log-line. Default is 50.
+	MaxLabelValues int
+	// DisableActionLabel keeps the Action in log-line instead of a label.
+	DisableActionLabel bool
+}
+
+// LokiTransport pushes log-entries to Grafana Loki.
+// ServiceName, Level and Action are used as stream-labels, and the remaining
+// fields are sent as JSON log-line.
+type LokiTransport struct {
+	config LokiConfig
+	sender *httpSender
+	batch  *batchSender
+
+	lock sync.Mutex
+	// labelValues tracks distinct values for each label for cardinality-guard
+	labelValues map[string]map[string]bool
+	// lastTime is the last pushed timestamp for each stream
+	lastTime map[string]int64
+}
+
+type lokiPushRequest struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// lokiLine is the log-line for entries.
+type lokiLine struct {
+	Description string `json:"description,omitempty"`
+	ErrorCode   int    `json:"errorCode,omitempty"`
+	Action      string `json:"action,omitempty"`
+	ServiceName string `json:"serviceName,omitempty"`
+	Level       string `json:"level,omitempty"`
+
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// NewLokiTransport creates a Transport pushing batches of entries to Loki.
+func NewLokiTransport(config LokiConfig) (*LokiTransport, error) {
+	if config.MaxLabelValues < 0 {
+		return nil, errors.New("MaxLabelValues cannot be negative")
+	}
+	if config.MaxLabelValues == 0 {
+		config.MaxLabelValues = 50
+	}
+
+	u, err := url.Parse(config.URL)
+	if err == nil && strings.Trim(u.Path, "/") == "" {
+		u.Path = lokiPushPath
+		config.URL = u.String()
+	}
+	config.MaxConcurrency = 1
+
+	sender, bc, err := newHTTPSender(config.HTTPConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &LokiTransport{
+		config:      config,
+		sender:      sender,
+		labelValues: map[string]map[string]bool{},
+		lastTime:    map[string]int64{},
+	}
+	t.batch = newBatchSender(redactURL(config.URL), bc, t.send)
+	return t, nil
+}
+
+// guardLabel returns the value to be used for label, replacing it with
+// LokiOtherValue if the label already has MaxLabelValues distinct values.
+// Lock must be held.
+func (t *LokiTransport) guardLabel(label string, value string) (string, bool) {
+	values, ok := t.labelValues[label]
+	if !ok {
+		values = map[string]bool{}
+		t.labelValues[label] = values
+	}
+	if values[value] {
+		return value, true
+	}
+	if len(values) >= t.config.MaxLabelValues {
+		return LokiOtherValue, false
+	}
+	values[value] = true
+	return value, true
+}
+
+// streamLabels returns the labels for the Record, and the log-line with the
+// fields not used as labels.
+func (t *LokiTransport) streamLabels(r *Record) (map[string]string, lokiLine) {
+	labels := map[string]string{}
+	for k, v := range t.config.StaticLabels {
+		labels[k] = v
+	}
+	line := lokiLine{
+		Description: strings.TrimRight(r.Entry.Description, "\n"),
+		ErrorCode:   r.Entry.ErrorCode,
+		Attachments: r.Attachments,
+	}
+
+	if r.Entry.ServiceName != "" {
+		v, ok := t.guardLabel(LokiLabelService, r.Entry.ServiceName)
+		labels[LokiLabelService] = v
+		if !ok {
+			line.ServiceName = r.Entry.ServiceName
+		}
+	}
+	if r.Entry.Level != "" {
+		v, ok := t.guardLabel(LokiLabelLevel, r.Entry.Level)
+		labels[LokiLabelLevel] = v
+		if !ok {
+			line.Level = r.Entry.Level
+		}
+	}
+	if r.Entry.Action != "" {
+		if t.config.DisableActionLabel {
+			line.Action = r.Entry.Action
+		} else {
+			v, ok := t.guardLabel(LokiLabelAction, r.Entry.Action)
+			labels[LokiLabelAction] = v
+			if !ok {
+				line.Action = r.Entry.Action
+			}
+		}
+	}
+	return labels, line
+}
+
+// streamKey returns a unique key for a label-set.
+func streamKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(strconv.Quote(k))
+		sb.WriteString("=")
+		sb.WriteString(strconv.Quote(labels[k]))
+		sb.WriteString(",")
+	}
+	return sb.String()
+}
+
+// pushRequest groups the Records into streams with values in timestamp-order.
+// Timestamps older than last pushed timestamp of a stream are raised to it,
+// so Loki does not reject the entries as out-of-order.
+func (t *LokiTransport) pushRequest(records []*Record) (lokiPushRequest, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	type streamEntry struct {
+		ts   int64
+		line string
+	}
+	keys := []string{}
+	streams := map[string]map[string]string{}
+	entries := map[string][]streamEntry{}
+
+	for _, r := range records {
+		labels, line := t.streamLabels(r)
+		lineBytes, err := json.Marshal(line)
+		if err != nil {
+			err = errors.Wrap(err, "Error marshalling log-line")
+			return lokiPushRequest{}, err
+		}
+
+		key := streamKey(labels)
+		if _, ok := streams[key]; !ok {
+			keys = append(keys, key)
+			streams[key] = labels
+		}
+		ts := r.Time
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		entries[key] = append(entries[key], streamEntry{
+			ts:   ts.UnixNano(),
+			line: string(lineBytes),
+		})
+	}
+
+	req := lokiPushRequest{}
+	for _, key := range keys {
+		se := entries[key]
+		sort.SliceStable(se, func(i, j int) bool {
+			return se[i].ts < se[j].ts
+		})
+
+		values := make([][2]string, len(se))
+		for i, e := range se {
+			if e.ts < t.lastTime[key] {
+				e.ts = t.lastTime[key]
+			}
+			t.lastTime[key] = e.ts
+			values[i] = [2]string{strconv.FormatInt(e.ts, 10), e.line}
+		}
+		req.Streams = append(req.Streams, lokiStream{
+			Stream: streams[key],
+			Values: values,
+		})
+	}
+	return req, nil
+}
+
+func (t *LokiTransport) send(records []*Record) error {
+	req, err := t.pushRequest(records)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		err = errors.Wrap(err, "Error marshalling Loki push-request")
+		return err
+	}
+	return t.sender.send(body, ContentTypeJSON)
+}
+
+// Write adds the Record to current batch.
+func (t *LokiTransport) Write(r *Record) error {
+	t.batch.add(r)
+	return nil
+}
+
+// Close pushes the remaining entries and waits for requests in flight.
+func (t *LokiTransport) Close() error {
+	t.sender.closeAfter(t.batch.close)
+	return nil
+}
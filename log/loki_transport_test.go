@@ -0,0 +1,157 @@
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/TerrexTech/go-common-models/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LokiTransport", func() {
+	var (
+		server  *httptest.Server
+		reqChan chan lokiPushRequest
+	)
+
+	BeforeEach(func() {
+		reqChan = make(chan lokiPushRequest, 10)
+		server = httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				defer GinkgoRecover()
+				Expect(r.URL.Path).To(Equal(lokiPushPath))
+				b, err := ioutil.ReadAll(r.Body)
+				Expect(err).ToNot(HaveOccurred())
+
+				req := lokiPushRequest{}
+				err = json.Unmarshal(b, &req)
+				Expect(err).ToNot(HaveOccurred())
+				reqChan <- req
+				w.WriteHeader(http.StatusNoContent)
+			},
+		))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should push entries grouped into streams in timestamp-order", func() {
+		t, err := NewLokiTransport(LokiConfig{
+			HTTPConfig: HTTPConfig{
+				URL: server.URL,
+			},
+			StaticLabels: map[string]string{"env": "test"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		now := time.Now()
+		records := []*Record{
+			{
+				Entry: model.LogEntry{
+					Action:      "test-action",
+					Description: "second\n",
+					ErrorCode:   4,
+					Level:       "ERROR",
+					ServiceName: "testsvc",
+				},
+				Time: now.Add(time.Second),
+			},
+			{
+				Entry: model.LogEntry{
+					Action:      "test-action",
+					Description: "first",
+					Level:       "ERROR",
+					ServiceName: "testsvc",
+				},
+				Time: now,
+			},
+			{
+				Entry: model.LogEntry{
+					Description: "other",
+					Level:       "INFO",
+					ServiceName: "testsvc",
+				},
+				Time: now,
+			},
+		}
+		for _, r := range records {
+			Expect(t.Write(r)).To(Succeed())
+		}
+		Expect(t.Close()).To(Succeed())
+
+		req := <-reqChan
+		Expect(req.Streams).To(HaveLen(2))
+		Expect(req.Streams[0].Stream).To(Equal(map[string]string{
+			"env":     "test",
+			"service": "testsvc",
+			"level":   "ERROR",
+			"action":  "test-action",
+		}))
+		Expect(req.Streams[0].Values).To(Equal([][2]string{
+			{strconv.FormatInt(now.UnixNano(), 10), `{"description":"first"}`},
+			{
+				strconv.FormatInt(now.Add(time.Second).UnixNano(), 10),
+				`{"description":"second","errorCode":4}`,
+			},
+		}))
+		Expect(req.Streams[1].Stream).To(Equal(map[string]string{
+			"env":     "test",
+			"service": "testsvc",
+			"level":   "INFO",
+		}))
+	})
+
+	It("should limit distinct label-values and keep the value in line", func() {
+		t, err := NewLokiTransport(LokiConfig{
+			HTTPConfig: HTTPConfig{
+				URL: server.URL,
+			},
+			MaxLabelValues: 2,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		for i := 0; i < 3; i++ {
+			err := t.Write(&Record{
+				Entry: model.LogEntry{
+					Action:      "action-" + strconv.Itoa(i),
+					Description: "test",
+					Level:       "INFO",
+				},
+				Time: time.Now(),
+			})
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(t.Close()).To(Succeed())
+
+		req := <-reqChan
+		Expect(req.Streams).To(HaveLen(3))
+		Expect(req.Streams[2].Stream[LokiLabelAction]).To(Equal(LokiOtherValue))
+		Expect(req.Streams[2].Values[0][1]).To(
+			Equal(`{"description":"test","action":"action-2"}`),
+		)
+	})
+
+	It("should not push older timestamps than already pushed for a stream", func() {
+		t, err := NewLokiTransport(LokiConfig{
+			HTTPConfig: HTTPConfig{
+				URL: server.URL,
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer t.Close()
+
+		now := time.Now()
+		entry := model.LogEntry{Description: "test", Level: "INFO"}
+		req, err := t.pushRequest([]*Record{{Entry: entry, Time: now}})
+		Expect(err).ToNot(HaveOccurred())
+		req, err = t.pushRequest([]*Record{{Entry: entry, Time: now.Add(-time.Hour)}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(req.Streams[0].Values[0][0]).To(Equal(strconv.FormatInt(now.UnixNano(), 10)))
+	})
+})
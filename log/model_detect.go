@@ -0,0 +1,257 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sync"
+
+	"github.com/TerrexTech/go-common-models/model"
+	"github.com/pkg/errors"
+)
+
+// ModelTypeKey is the key added to the JSON objects detected as one of
+// ModelSchemas, with the schema's Name as value.
+const ModelTypeKey = "@type"
+
+// maxDataDepth is the maximum nesting of JSON Data, such as Data of an Event
+// in Data of a Document, decoded in attachments. Deeper Data is kept as string.
+const maxDataDepth = 8
+
+// ModelSchema describes a type detected in the nested JSON Data of attachments,
+// such as of Common-Models. A JSON object is detected as the type only if it
+// has all the Required keys, has no keys other than the JSON fields of the type,
+// and can be decoded into the type.
+type ModelSchema struct {
+	// Name is the value of ModelTypeKey in detected objects.
+	// Default is the Go type-name, such as "model.Event".
+	Name string
+	// Type is the struct-type detected objects are decoded into.
+	Type reflect.Type
+	// Required are the JSON keys which must be present in detected objects.
+	// At least one key is required.
+	Required []string
+	// DataKey is the JSON key of a []byte field containing nested JSON,
+	// such as "data", which is decoded and detected recursively. Optional.
+	DataKey string
+}
+
+// DefaultModelSchemas are the ModelSchemas of Common-Models, which are used
+// by default. The required keys of each model are not all present in others.
+var DefaultModelSchemas = []ModelSchema{
+	{
+		Type:     reflect.TypeOf(model.Event{}),
+		Required: []string{"action", "aggregateID", "uuid"},
+		DataKey:  "data",
+	},
+	{
+		Type:     reflect.TypeOf(model.Command{}),
+		Required: []string{"action", "timestamp", "uuid"},
+		DataKey:  "data",
+	},
+	{
+		Type:     reflect.TypeOf(model.Document{}),
+		Required: []string{"topic", "uuid"},
+		DataKey:  "data",
+	},
+}
+
+// modelSchema is a validated ModelSchema.
+type modelSchema struct {
+	ModelSchema
+	// fields are the JSON keys of Type's fields
+	fields map[string]bool
+}
+
+var modelSchemas = struct {
+	sync.RWMutex
+	schemas []modelSchema
+}{}
+
+func init() {
+	err := SetModelSchemas(DefaultModelSchemas...)
+	if err != nil {
+		panic(err)
+	}
+	for _, s := range DefaultModelSchemas {
+		RegisterFormatter(s.Type, fmtKnownTypes)
+	}
+}
+
+// SetModelSchemas sets the types detected in the nested JSON Data of
+// attachments, such as to add domain-types to DefaultModelSchemas. The schemas
+// are tried in order, and the first detected type is used. Detection is
+// disabled if no schemas are provided.
+func SetModelSchemas(schemas ...ModelSchema) error {
+	validated := make([]modelSchema, len(schemas))
+	for i, s := range schemas {
+		ms, err := newModelSchema(s)
+		if err != nil {
+			err = errors.Wrapf(err, "schema %d", i)
+			return err
+		}
+		validated[i] = ms
+	}
+
+	modelSchemas.Lock()
+	modelSchemas.schemas = validated
+	modelSchemas.Unlock()
+	return nil
+}
+
+func newModelSchema(s ModelSchema) (modelSchema, error) {
+	if s.Type == nil {
+		return modelSchema{}, errors.New("Type is required")
+	}
+	if s.Type.Kind() == reflect.Ptr {
+		s.Type = s.Type.Elem()
+	}
+	if s.Type.Kind() != reflect.Struct {
+		return modelSchema{}, errors.Errorf("Type %s is not a struct", s.Type)
+	}
+	if len(s.Required) == 0 {
+		return modelSchema{}, errors.New("at least one Required key is needed")
+	}
+	if s.Name == "" {
+		s.Name = s.Type.String()
+	}
+
+	ms := modelSchema{
+		ModelSchema: s,
+		fields:      map[string]bool{},
+	}
+	isDataField := false
+	walkJSONFields(
+		reflect.New(s.Type).Elem(),
+		func(name string, f reflect.StructField, fv reflect.Value) {
+			ms.fields[name] = true
+			if name == s.DataKey && f.Type == reflect.TypeOf([]byte{}) {
+				isDataField = true
+			}
+		},
+	)
+	for _, key := range s.Required {
+		if !ms.fields[key] {
+			return modelSchema{}, errors.Errorf("Required key %s is not a field", key)
+		}
+	}
+	if s.DataKey != "" && !isDataField {
+		return modelSchema{}, errors.Errorf(
+			"DataKey %s is not a []byte field", s.DataKey,
+		)
+	}
+	return ms, nil
+}
+
+// fmtKnownTypes is the formatter registered for Common-Models,
+// which decodes their nested Data.
+func fmtKnownTypes(d interface{}) interface{} {
+	m := map[string]interface{}{}
+	if decodeJSON(d, &m) != nil {
+		return d
+	}
+	return modelValue(d, m, "data", "", 0)
+}
+
+// modelValue decodes the nested JSON Data at dataKey of model d in its JSON
+// object m. The object is annotated with name if set.
+func modelValue(
+	d interface{}, m map[string]interface{}, dataKey string, name string, depth int,
+) map[string]interface{} {
+	if name != "" {
+		m[ModelTypeKey] = name
+	}
+	if dataKey == "" {
+		return m
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(d))
+	walkJSONFields(v, func(key string, f reflect.StructField, fv reflect.Value) {
+		if key != dataKey || !fv.CanInterface() {
+			return
+		}
+		data, ok := fv.Interface().([]byte)
+		if _, exists := m[key]; ok && exists {
+			m[key] = decodeData(data, depth+1)
+		}
+	})
+	return m
+}
+
+// decodeData decodes nested JSON Data, and detects the ModelSchemas in it.
+// Data which isn't JSON, or is nested deeper than maxDataDepth,
+// is returned as string.
+func decodeData(data []byte, depth int) interface{} {
+	if depth > maxDataDepth {
+		return string(data)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	if decoder.Decode(&v) != nil || decoder.More() {
+		return string(data)
+	}
+
+	modelSchemas.RLock()
+	schemas := modelSchemas.schemas
+	modelSchemas.RUnlock()
+	return detectModels(v, schemas, depth)
+}
+
+// detectModels replaces the JSON objects in v, at any level,
+// which are detected as one of schemas.
+func detectModels(v interface{}, schemas []modelSchema, depth int) interface{} {
+	switch t := v.(type) {
+	case []interface{}:
+		for i := range t {
+			t[i] = detectModels(t[i], schemas, depth)
+		}
+	case map[string]interface{}:
+		for _, s := range schemas {
+			if d, ok := s.detect(t); ok {
+				return modelValue(d, t, s.DataKey, s.Name, depth)
+			}
+		}
+		for k := range t {
+			t[k] = detectModels(t[k], schemas, depth)
+		}
+	}
+	return v
+}
+
+// detect decodes m into the schema's Type if m matches the schema.
+func (s modelSchema) detect(m map[string]interface{}) (interface{}, bool) {
+	for _, key := range s.Required {
+		if _, ok := m[key]; !ok {
+			return nil, false
+		}
+	}
+	for key := range m {
+		if !s.fields[key] {
+			return nil, false
+		}
+	}
+
+	ml, err := json.Marshal(m)
+	if err != nil {
+		return nil, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(ml))
+	decoder.DisallowUnknownFields()
+	d := reflect.New(s.Type)
+	if decoder.Decode(d.Interface()) != nil {
+		return nil, false
+	}
+	return d.Interface(), true
+}
+
+// decodeJSON converts v to its generic JSON value, keeping the numbers as is.
+func decodeJSON(v interface{}, generic interface{}) error {
+	ml, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(ml))
+	decoder.UseNumber()
+	return decoder.Decode(generic)
+}
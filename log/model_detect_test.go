@@ -0,0 +1,159 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/TerrexTech/go-common-models/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ModelDetection", func() {
+	const (
+		uuid  = "4b5fe8ae-d3ff-4a42-b4de-2bd1d9d5de1a"
+		event = `{"action":"insert","aggregateID":2,"uuid":"` + uuid + `",` +
+			`"data":"eyJpZCI6MX0="}`
+	)
+
+	// decodeAttachment returns the decoded Value of attachment.
+	decodeAttachment := func(d interface{}) map[string]interface{} {
+		a := newAttachment(0, d, DefaultFormatLimits, nil)
+		Expect(a.Error).To(BeEmpty())
+		value := map[string]interface{}{}
+		Expect(json.Unmarshal(a.Value, &value)).To(Succeed())
+		return value
+	}
+
+	AfterEach(func() {
+		Expect(SetModelSchemas(DefaultModelSchemas...)).To(Succeed())
+	})
+
+	It("should detect models in Data at every level", func() {
+		doc := model.Document{
+			Topic: "test",
+			Data: []byte(
+				`{"events":[` + event + `],"event":` + event + `,"id":3}`,
+			),
+		}
+		value := decodeAttachment(doc)
+		Expect(value["topic"]).To(Equal("test"))
+		Expect(value).ToNot(HaveKey(ModelTypeKey))
+
+		detected := map[string]interface{}{
+			ModelTypeKey:  "model.Event",
+			"action":      "insert",
+			"aggregateID": 2.0,
+			"uuid":        uuid,
+			"data":        map[string]interface{}{"id": 1.0},
+		}
+		Expect(value["data"]).To(Equal(map[string]interface{}{
+			"events": []interface{}{detected},
+			"event":  detected,
+			"id":     3.0,
+		}))
+	})
+
+	It("should not detect partial or overlapping payloads", func() {
+		cases := []string{
+			// Missing the required aggregateID
+			`{"action":"insert","uuid":"` + uuid + `"}`,
+			// Has keys other than the Event fields
+			`{"action":"insert","aggregateID":2,"uuid":"` + uuid + `","name":"a"}`,
+			// Invalid type of aggregateID
+			`{"action":"insert","aggregateID":"2","uuid":"` + uuid + `"}`,
+		}
+		for _, c := range cases {
+			value := decodeAttachment(model.Document{Data: []byte(c)})
+			data := value["data"].(map[string]interface{})
+			Expect(data).ToNot(HaveKey(ModelTypeKey), c)
+		}
+
+		value := decodeAttachment(model.Document{Data: []byte("not json")})
+		Expect(value["data"]).To(Equal("not json"))
+	})
+
+	It("should detect the configured types", func() {
+		type product struct {
+			SKU  string `json:"sku"`
+			Name string `json:"name,omitempty"`
+			Data []byte `json:"data,omitempty"`
+		}
+		err := SetModelSchemas(ModelSchema{
+			Name:     "product",
+			Type:     reflect.TypeOf(&product{}),
+			Required: []string{"sku"},
+			DataKey:  "data",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		value := decodeAttachment(model.Event{
+			Data: []byte(`[{"sku":"a1","data":"eyJza3UiOiJiMiJ9"},` + event + `]`),
+		})
+		Expect(value["data"]).To(Equal([]interface{}{
+			map[string]interface{}{
+				ModelTypeKey: "product",
+				"sku":        "a1",
+				"data": map[string]interface{}{
+					ModelTypeKey: "product",
+					"sku":        "b2",
+				},
+			},
+			// Events are not detected anymore, so data is kept as base64
+			map[string]interface{}{
+				"action":      "insert",
+				"aggregateID": 2.0,
+				"uuid":        uuid,
+				"data":        "eyJpZCI6MX0=",
+			},
+		}))
+	})
+
+	It("should keep Data nested deeper than max depth as string", func() {
+		type wrapper struct {
+			ID   int    `json:"id"`
+			Data []byte `json:"data,omitempty"`
+		}
+		err := SetModelSchemas(ModelSchema{
+			Type:     reflect.TypeOf(wrapper{}),
+			Required: []string{"id"},
+			DataKey:  "data",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		var data []byte
+		for i := maxDataDepth + 1; i > 0; i-- {
+			data, err = json.Marshal(wrapper{ID: i, Data: data})
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		// Data of the Attachment's value is at depth 1
+		value := decodeData(data, 1)
+		for i := 1; i < maxDataDepth; i++ {
+			Expect(value).To(HaveKeyWithValue("id", json.Number(fmt.Sprint(i))))
+			value = value.(map[string]interface{})["data"]
+		}
+		Expect(value).To(HaveKeyWithValue(ModelTypeKey, "log.wrapper"))
+		Expect(value.(map[string]interface{})["data"]).To(BeAssignableToTypeOf(""))
+	})
+
+	It("should validate the schemas", func() {
+		cases := []ModelSchema{
+			{Required: []string{"uuid"}},
+			{Type: reflect.TypeOf(""), Required: []string{"uuid"}},
+			{Type: reflect.TypeOf(model.Event{})},
+			{Type: reflect.TypeOf(model.Event{}), Required: []string{"name"}},
+			{
+				Type:     reflect.TypeOf(model.Event{}),
+				Required: []string{"uuid"},
+				DataKey:  "action",
+			},
+		}
+		for _, c := range cases {
+			err := SetModelSchemas(DefaultModelSchemas[0], c)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("schema 1: "))
+		}
+	})
+})
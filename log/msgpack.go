@@ -0,0 +1,275 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// msgpackEventTimeExt is the ext-type of Fluentd's EventTime.
+const msgpackEventTimeExt = 0
+
+// msgpackBuffer encodes the MessagePack types used by this package.
+type msgpackBuffer struct {
+	bytes.Buffer
+}
+
+// header writes a type-byte followed by big-endian length or value of the
+// provided size in bytes.
+func (b *msgpackBuffer) header(t byte, v uint64, size int) {
+	b.WriteByte(t)
+	for i := size - 1; i >= 0; i-- {
+		b.WriteByte(byte(v >> uint(8*i)))
+	}
+}
+
+func (b *msgpackBuffer) writeMapHeader(n int) {
+	switch {
+	case n < 16:
+		b.WriteByte(0x80 | byte(n))
+	case n <= math.MaxUint16:
+		b.header(0xde, uint64(n), 2)
+	default:
+		b.header(0xdf, uint64(n), 4)
+	}
+}
+
+func (b *msgpackBuffer) writeArrayHeader(n int) {
+	switch {
+	case n < 16:
+		b.WriteByte(0x90 | byte(n))
+	case n <= math.MaxUint16:
+		b.header(0xdc, uint64(n), 2)
+	default:
+		b.header(0xdd, uint64(n), 4)
+	}
+}
+
+func (b *msgpackBuffer) writeString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		b.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		b.header(0xd9, uint64(n), 1)
+	case n <= math.MaxUint16:
+		b.header(0xda, uint64(n), 2)
+	default:
+		b.header(0xdb, uint64(n), 4)
+	}
+	b.WriteString(s)
+}
+
+func (b *msgpackBuffer) writeBin(p []byte) {
+	n := len(p)
+	switch {
+	case n <= math.MaxUint8:
+		b.header(0xc4, uint64(n), 1)
+	case n <= math.MaxUint16:
+		b.header(0xc5, uint64(n), 2)
+	default:
+		b.header(0xc6, uint64(n), 4)
+	}
+	b.Write(p)
+}
+
+func (b *msgpackBuffer) writeInt(v int64) {
+	switch {
+	case v >= 0 && v < 128:
+		b.WriteByte(byte(v))
+	case v < 0 && v >= -32:
+		b.WriteByte(byte(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		b.header(0xd2, uint64(uint32(v)), 4)
+	default:
+		b.header(0xd3, uint64(v), 8)
+	}
+}
+
+// writeEventTime writes t as Fluentd EventTime, which has nanosecond precision.
+func (b *msgpackBuffer) writeEventTime(t time.Time) {
+	b.WriteByte(0xd7)
+	b.WriteByte(msgpackEventTimeExt)
+	var p [8]byte
+	binary.BigEndian.PutUint32(p[:4], uint32(t.Unix()))
+	binary.BigEndian.PutUint32(p[4:], uint32(t.Nanosecond()))
+	b.Write(p[:])
+}
+
+// msgpackExt is a decoded MessagePack extension-value.
+type msgpackExt struct {
+	Type int8
+	Data []byte
+}
+
+// msgpackDecoder decodes MessagePack values into nil, bool, int64, uint64,
+// float64, string, []byte, []interface{}, map[string]interface{} and msgpackExt.
+// Map-keys which are not strings are formatted using fmt.Sprint.
+type msgpackDecoder struct {
+	r io.Reader
+	// maxLength, if positive, limits the lengths of strings, binaries,
+	// extensions, arrays and maps, so malformed input cannot cause large
+	// allocations.
+	maxLength int
+}
+
+// checkLength returns an error if the length n exceeds maxLength.
+func (d *msgpackDecoder) checkLength(n int) error {
+	if d.maxLength > 0 && (n < 0 || n > d.maxLength) {
+		return errors.Errorf("MessagePack length %d exceeds maximum %d", n, d.maxLength)
+	}
+	return nil
+}
+
+func (d *msgpackDecoder) read(n int) ([]byte, error) {
+	if err := d.checkLength(n); err != nil {
+		return nil, err
+	}
+	p := make([]byte, n)
+	_, err := io.ReadFull(d.r, p)
+	return p, err
+}
+
+func (d *msgpackDecoder) uint(size int) (uint64, error) {
+	p, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range p {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func (d *msgpackDecoder) decode() (interface{}, error) {
+	p, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	t := p[0]
+
+	switch {
+	case t <= 0x7f:
+		return int64(t), nil
+	case t >= 0xe0:
+		return int64(int8(t)), nil
+	case t&0xf0 == 0x80:
+		return d.decodeMap(int(t & 0x0f))
+	case t&0xf0 == 0x90:
+		return d.decodeArray(int(t & 0x0f))
+	case t&0xe0 == 0xa0:
+		p, err := d.read(int(t & 0x1f))
+		return string(p), err
+	}
+
+	switch t {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (t - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return d.read(int(n))
+	case 0xca:
+		v, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := d.uint(8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.uint(1 << (t - 0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (t - 0xd0)
+		v, err := d.uint(size)
+		shift := uint(64 - 8*size)
+		return int64(v<<shift) >> shift, err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExt(1 << (t - 0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.uint(1 << (t - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(int(n))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (t - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		p, err := d.read(int(n))
+		return string(p), err
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (t - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (t - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(int(n))
+	}
+	return nil, errors.Errorf("unsupported MessagePack type: 0x%x", t)
+}
+
+func (d *msgpackDecoder) decodeExt(n int) (interface{}, error) {
+	p, err := d.read(n + 1)
+	if err != nil {
+		return nil, err
+	}
+	return msgpackExt{
+		Type: int8(p[0]),
+		Data: p[1:],
+	}, nil
+}
+
+func (d *msgpackDecoder) decodeArray(n int) (interface{}, error) {
+	if err := d.checkLength(n); err != nil {
+		return nil, err
+	}
+	arr := make([]interface{}, n)
+	for i := range arr {
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = v
+	}
+	return arr, nil
+}
+
+func (d *msgpackDecoder) decodeMap(n int) (interface{}, error) {
+	if err := d.checkLength(n); err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			key = fmt.Sprint(k)
+		}
+		m[key] = v
+	}
+	return m, nil
+}
@@ -0,0 +1,92 @@
+package log
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// reconnectingConn writes to a network-connection which is established lazily,
+// and re-established when a write fails.
+type reconnectingConn struct {
+	// network is one of "udp", "tcp", "unix" or "tls"
+	network       string
+	address       string
+	tlsConfig     *tls.Config
+	dialTimeout   time.Duration
+	writeTimeout  time.Duration
+	maxReconnects int
+
+	conn net.Conn
+}
+
+// write writes b to connection, reconnecting up to maxReconnects times on failure.
+func (c *reconnectingConn) write(b []byte) error {
+	var err error
+	for attempt := 0; attempt <= c.maxReconnects; attempt++ {
+		if c.conn == nil {
+			err = c.connect()
+			if err != nil {
+				continue
+			}
+		}
+
+		err = c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+		if err == nil {
+			_, err = c.conn.Write(b)
+		}
+		if err == nil {
+			return nil
+		}
+		c.conn.Close()
+		c.conn = nil
+	}
+	return err
+}
+
+func (c *reconnectingConn) connect() error {
+	dialer := &net.Dialer{
+		Timeout: c.dialTimeout,
+	}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if c.network == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.address, c.tlsConfig)
+	} else {
+		conn, err = dialer.Dial(c.network, c.address)
+	}
+	if err != nil {
+		err = errors.Wrapf(err, "Error connecting to %s", c.address)
+		return err
+	}
+	c.conn = conn
+	return nil
+}
+
+// read reads from the current connection, such as acknowledgements of
+// written messages, waiting at most timeout.
+func (c *reconnectingConn) read(b []byte, timeout time.Duration) (int, error) {
+	if c.conn == nil {
+		return 0, errors.New("connection is not established")
+	}
+	err := c.conn.SetReadDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return 0, err
+	}
+	return c.conn.Read(b)
+}
+
+// close closes the current connection, if any.
+func (c *reconnectingConn) close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
@@ -0,0 +1,424 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/Shopify/sarama"
+	"github.com/TerrexTech/go-kafkautils/kafka"
+)
+
+// ConfigError describes an invalid setting provided to Init or InitWithOptions.
+type ConfigError struct {
+	// Field is the invalid setting, such as "Topic" or "BufferSize".
+	Field  string
+	Reason string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// Option configures the Logger created by InitWithOptions.
+type Option func(o *options) error
+
+// transportOption is a Transport added using WithTransport.
+type transportOption struct {
+	transport Transport
+	config    TransportConfig
+}
+
+type options struct {
+	kafkaConfig  *kafka.ProducerConfig
+	kafkaTLS     *KafkaTLS
+	kafkaSASL    *KafkaSASL
+	producer     sarama.AsyncProducer
+	topic        string
+	topicCheck   *TopicCheck
+	bufferSize   int
+	output       io.Writer
+	formatter    Formatter
+	level        string
+	transports   []transportOption
+	keyStrategy  KeyStrategy
+	action       string
+	arrThreshold int
+	limits       *FormatLimits
+	redactor     *redactor
+
+	configFile  string
+	configWatch bool
+}
+
+// WithKafka produces log-entries to Kafka using a producer created from config.
+// Requires WithTopic.
+func WithKafka(config *kafka.ProducerConfig) Option {
+	return func(o *options) error {
+		if config == nil {
+			return &ConfigError{"KafkaConfig", "nil config provided"}
+		}
+		if len(config.KafkaBrokers) == 0 {
+			return &ConfigError{"KafkaConfig", "no Kafka brokers provided"}
+		}
+		o.kafkaConfig = config
+		return nil
+	}
+}
+
+// WithKafkaTLS enables TLS for connecting to the brokers provided to WithKafka.
+// The certificate-files are loaded when the Logger is created.
+func WithKafkaTLS(config KafkaTLS) Option {
+	return func(o *options) error {
+		o.kafkaTLS = &config
+		return nil
+	}
+}
+
+// WithKafkaSASL enables SASL authentication with the brokers provided to WithKafka.
+func WithKafkaSASL(config KafkaSASL) Option {
+	return func(o *options) error {
+		err := config.validate()
+		if err != nil {
+			return &ConfigError{"KafkaSASL", err.Error()}
+		}
+		o.kafkaSASL = &config
+		return nil
+	}
+}
+
+// withoutKafka clears the Kafka settings of preceding options,
+// such as for Init when LOG_SINK is "stdout".
+func withoutKafka() Option {
+	return func(o *options) error {
+		o.kafkaConfig, o.kafkaTLS, o.kafkaSASL = nil, nil, nil
+		o.producer, o.topic, o.topicCheck, o.bufferSize = nil, "", nil, 0
+		return nil
+	}
+}
+
+// WithProducer produces log-entries to Kafka using an existing producer,
+// such as a mock producer in tests. Requires WithTopic.
+func WithProducer(producer sarama.AsyncProducer) Option {
+	return func(o *options) error {
+		if producer == nil {
+			return &ConfigError{"Producer", "nil producer provided"}
+		}
+		o.producer = producer
+		return nil
+	}
+}
+
+// WithTopic sets the Kafka topic to which log-entries are produced.
+func WithTopic(topic string) Option {
+	return func(o *options) error {
+		if topic == "" {
+			return &ConfigError{"Topic", "empty topic provided"}
+		}
+		o.topic = topic
+		return nil
+	}
+}
+
+// WithTopicCheck verifies that the topic exists when the Logger is created,
+// using Kafka's admin API, and creates it if check.Create is set.
+// If the topic does not exist and is not created, a *ConfigError is returned.
+// Requires WithKafka, whose SaramaConfig's Version must be at least
+// V0_10_1_0 for creating topics.
+func WithTopicCheck(check TopicCheck) Option {
+	return func(o *options) error {
+		if check.NumPartitions < 0 || check.ReplicationFactor < 0 {
+			return &ConfigError{
+				"TopicCheck", "NumPartitions and ReplicationFactor cannot be negative",
+			}
+		}
+		if check.Retention < 0 {
+			return &ConfigError{"TopicCheck", "Retention cannot be negative"}
+		}
+		o.topicCheck = &check
+		return nil
+	}
+}
+
+// WithBufferSize sets the number of entries queued for Kafka before new
+// entries are dropped. Default is DefaultBufferSize.
+func WithBufferSize(size int) Option {
+	return func(o *options) error {
+		if size <= 0 {
+			return &ConfigError{"BufferSize", "must be positive"}
+		}
+		o.bufferSize = size
+		return nil
+	}
+}
+
+// WithOutput sets the output to which the logs are written. Default is Stdout.
+func WithOutput(w io.Writer) Option {
+	return func(o *options) error {
+		if w == nil {
+			return &ConfigError{"Output", "nil writer provided"}
+		}
+		o.output = w
+		return nil
+	}
+}
+
+// WithFormatter sets the Formatter used for writing logs to output, such as
+// TextFormatter. Default is DebugFormatter.
+func WithFormatter(f Formatter) Option {
+	return func(o *options) error {
+		if f == nil {
+			return &ConfigError{"Formatter", "nil formatter provided"}
+		}
+		o.formatter = f
+		return nil
+	}
+}
+
+// WithLevel sets the log-level, which is otherwise read from the LOG_LEVEL
+// environment variable. Valid levels are: DEBUG, INFO, ERROR and NONE.
+func WithLevel(level string) Option {
+	return func(o *options) error {
+		if _, ok := levelRank[level]; !ok && level != "NONE" {
+			reason := fmt.Sprintf(
+				"invalid level %s, valid levels are: DEBUG, INFO, ERROR and NONE", level,
+			)
+			return &ConfigError{"Level", reason}
+		}
+		o.level = level
+		return nil
+	}
+}
+
+// WithTransport adds a destination to which the log-entries are sent.
+func WithTransport(t Transport, config TransportConfig) Option {
+	return func(o *options) error {
+		if t == nil {
+			return &ConfigError{"Transport", "nil transport provided"}
+		}
+		if _, ok := levelRank[config.Level]; !ok && config.Level != "" {
+			reason := fmt.Sprintf(
+				"invalid level %s for transport %s", config.Level, config.Name,
+			)
+			return &ConfigError{"Transport", reason}
+		}
+		o.transports = append(o.transports, transportOption{t, config})
+		return nil
+	}
+}
+
+// WithKeyStrategy sets the strategy used to determine the Kafka message-key.
+func WithKeyStrategy(strategy KeyStrategy) Option {
+	return func(o *options) error {
+		if strategy == nil {
+			return &ConfigError{"KeyStrategy", "nil strategy provided"}
+		}
+		o.keyStrategy = strategy
+		return nil
+	}
+}
+
+// WithAction sets default Action for logging if none is set in Entry.
+func WithAction(action string) Option {
+	return func(o *options) error {
+		o.action = action
+		return nil
+	}
+}
+
+// WithArrayThreshold sets threshold for array-length. Arrays exceeding this
+// length will be trimmed. Default value is 15.
+func WithArrayThreshold(threshold int) Option {
+	return func(o *options) error {
+		if threshold <= 0 {
+			return &ConfigError{"ArrayThreshold", "must be positive"}
+		}
+		o.arrThreshold = threshold
+		return nil
+	}
+}
+
+// WithFormatLimits sets the limits for data attached at DEBUG level.
+// Default is DefaultFormatLimits. WithArrayThreshold overrides the
+// ArrayThreshold, if both are used.
+func WithFormatLimits(limits FormatLimits) Option {
+	return func(o *options) error {
+		err := limits.validate()
+		if err != nil {
+			return &ConfigError{"FormatLimits", err.Error()}
+		}
+		o.limits = &limits
+		return nil
+	}
+}
+
+// WithRedaction sets the rules for masking sensitive data in attachments
+// and descriptions. Struct-fields tagged `log:"redact"` are always masked.
+func WithRedaction(rules ...RedactionRule) Option {
+	return func(o *options) error {
+		r, err := newRedactor(rules)
+		if err != nil {
+			return &ConfigError{"Redaction", err.Error()}
+		}
+		o.redactor = r
+		return nil
+	}
+}
+
+// WithConfigFile applies the LogConfig from a YAML or JSON file, see LoadLogConfig.
+// If watch is set, the file is watched for changes using filesystem
+// notifications, and the changed settings are applied and logged.
+// A changed file which is invalid is rejected, and the current config is kept.
+func WithConfigFile(path string, watch bool) Option {
+	return func(o *options) error {
+		if path == "" {
+			return &ConfigError{"ConfigFile", "empty path provided"}
+		}
+		o.configFile = path
+		o.configWatch = watch
+		return nil
+	}
+}
+
+// InitWithOptions creates a new Logger configured using options.
+// Log-entries are produced to Kafka if WithKafka or WithProducer is provided,
+// and are only written to output if neither is provided nor any Transport,
+// including the ones declared in the file provided to WithConfigFile.
+// All options are validated before anything is created, and a *ConfigError
+// is returned for the first invalid setting.
+// Transports are closed when the context is closed.
+func InitWithOptions(
+	ctx context.Context,
+	// svcName is the default ServiceName to be used
+	// when ServiceName is not provided in LogEntry model.
+	svcName string,
+	opts ...Option,
+) (Logger, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if svcName == "" {
+		return nil, &ConfigError{"svcName", "empty svcName provided"}
+	}
+
+	o := &options{}
+	for _, opt := range opts {
+		err := opt(o)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	useKafka := o.kafkaConfig != nil || o.producer != nil
+	switch {
+	case o.kafkaConfig != nil && o.producer != nil:
+		return nil, &ConfigError{"Producer", "cannot be used with WithKafka"}
+	case useKafka && o.topic == "":
+		return nil, &ConfigError{"Topic", "required when Kafka is configured"}
+	case !useKafka && o.topic != "":
+		return nil, &ConfigError{"Topic", "requires WithKafka or WithProducer"}
+	case !useKafka && o.bufferSize != 0:
+		return nil, &ConfigError{"BufferSize", "requires WithKafka or WithProducer"}
+	case o.kafkaConfig == nil && o.kafkaTLS != nil:
+		return nil, &ConfigError{"KafkaTLS", "requires WithKafka"}
+	case o.kafkaConfig == nil && o.kafkaSASL != nil:
+		return nil, &ConfigError{"KafkaSASL", "requires WithKafka"}
+	case o.kafkaConfig == nil && o.topicCheck != nil:
+		return nil, &ConfigError{"TopicCheck", "requires WithKafka"}
+	}
+
+	if o.kafkaTLS != nil || o.kafkaSASL != nil {
+		// The provided config is copied, so it is not modified
+		config := *o.kafkaConfig
+		config.SaramaConfig = copySaramaConfig(config.SaramaConfig)
+		if o.kafkaTLS != nil {
+			err := o.kafkaTLS.apply(config.SaramaConfig)
+			if err != nil {
+				return nil, &ConfigError{"KafkaTLS", err.Error()}
+			}
+		}
+		if o.kafkaSASL != nil {
+			err := o.kafkaSASL.apply(config.SaramaConfig)
+			if err != nil {
+				return nil, &ConfigError{"KafkaSASL", err.Error()}
+			}
+		}
+		o.kafkaConfig = &config
+	}
+	if o.topicCheck != nil {
+		err := checkTopic(o.kafkaConfig, o.topic, *o.topicCheck)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := newLogger(svcName)
+	if err != nil {
+		return nil, err
+	}
+	if o.output != nil {
+		l.output = o.output
+	}
+	if o.formatter != nil {
+		l.formatter = o.formatter
+	}
+	if o.keyStrategy != nil {
+		l.keyStrategy = o.keyStrategy
+	}
+	if o.limits != nil {
+		l.limits = *o.limits
+	}
+	if o.arrThreshold > 0 {
+		l.limits.ArrayThreshold = o.arrThreshold
+	}
+	if o.redactor != nil {
+		l.redactor = o.redactor
+	}
+	l.level = o.level
+	l.action = o.action
+
+	if useKafka {
+		var kt *KafkaTransport
+		if o.producer != nil {
+			kt, err = NewKafkaTransportFromProducer(o.producer, o.topic)
+		} else {
+			kt, err = NewKafkaTransport(o.kafkaConfig, o.topic)
+		}
+		if err != nil {
+			return nil, err
+		}
+		o.transports = append([]transportOption{{
+			transport: kt,
+			config: TransportConfig{
+				Name:       "kafka",
+				BufferSize: o.bufferSize,
+			},
+		}}, o.transports...)
+	}
+
+	for _, t := range o.transports {
+		err = l.AddTransport(t.transport, t.config)
+		if err != nil {
+			l.transports.close()
+			return nil, err
+		}
+	}
+
+	if o.configFile != "" {
+		loader := newConfigLoader(l, o.configFile)
+		err = loader.load()
+		if err != nil {
+			l.transports.close()
+			return nil, err
+		}
+		if o.configWatch {
+			err = loader.watch(ctx)
+			if err != nil {
+				l.transports.close()
+				return nil, err
+			}
+		}
+	}
+	l.closeOnDone(ctx)
+	return l, nil
+}
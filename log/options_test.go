@@ -0,0 +1,142 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/TerrexTech/go-common-models/model"
+	"github.com/TerrexTech/go-kafkautils/kafka"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("InitWithOptions", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	// configField returns the Field of the *ConfigError returned by InitWithOptions.
+	configField := func(svcName string, opts ...Option) string {
+		_, err := InitWithOptions(ctx, svcName, opts...)
+		Expect(err).To(HaveOccurred())
+		configErr, ok := err.(*ConfigError)
+		Expect(ok).To(BeTrue())
+		return configErr.Field
+	}
+
+	It("should return ConfigError for invalid settings", func() {
+		broker := &kafka.ProducerConfig{KafkaBrokers: []string{"kafka:9092"}}
+		producer := mocks.NewAsyncProducer(&mockReporter{}, nil)
+
+		cases := []struct {
+			field   string
+			svcName string
+			opts    []Option
+		}{
+			{"svcName", "", nil},
+			{"KafkaConfig", "testsvc", []Option{WithKafka(nil)}},
+			{"KafkaConfig", "testsvc", []Option{WithKafka(&kafka.ProducerConfig{})}},
+			{"Producer", "testsvc", []Option{WithProducer(nil)}},
+			{"Topic", "testsvc", []Option{WithTopic("")}},
+			{"BufferSize", "testsvc", []Option{WithBufferSize(0)}},
+			{"Output", "testsvc", []Option{WithOutput(nil)}},
+			{"Level", "testsvc", []Option{WithLevel("WARN")}},
+			{"Transport", "testsvc", []Option{WithTransport(nil, TransportConfig{})}},
+			{"Transport", "testsvc", []Option{
+				WithTransport(&mockTransport{}, TransportConfig{Level: "WARN"}),
+			}},
+			{"KeyStrategy", "testsvc", []Option{WithKeyStrategy(nil)}},
+			{"ArrayThreshold", "testsvc", []Option{WithArrayThreshold(0)}},
+			// Cross-option validation
+			{"Topic", "testsvc", []Option{WithProducer(producer)}},
+			{"Topic", "testsvc", []Option{WithTopic("test-topic")}},
+			{"BufferSize", "testsvc", []Option{WithBufferSize(10)}},
+			{"Producer", "testsvc", []Option{
+				WithKafka(broker), WithProducer(producer), WithTopic("test-topic"),
+			}},
+		}
+		for _, c := range cases {
+			Expect(configField(c.svcName, c.opts...)).To(Equal(c.field))
+		}
+	})
+
+	It("should return ConfigError from Init if svcName is empty", func() {
+		_, err := Init(ctx, "", &kafka.ProducerConfig{}, "test-topic")
+		Expect(err).To(Equal(&ConfigError{"svcName", "empty svcName provided"}))
+	})
+
+	It("should produce to provided producer with options applied", func() {
+		config := sarama.NewConfig()
+		config.Producer.Return.Successes = true
+		reporter := &mockReporter{}
+		producer := mocks.NewAsyncProducer(reporter, config)
+		producer.ExpectInputAndSucceed()
+
+		output := &bytes.Buffer{}
+		l, err := InitWithOptions(
+			ctx,
+			"testsvc",
+			WithProducer(producer),
+			WithTopic("test-topic"),
+			WithBufferSize(10),
+			WithOutput(output),
+			WithLevel("ERROR"),
+			WithAction("default-action"),
+		)
+		Expect(err).ToNot(HaveOccurred())
+
+		l.I(Entry{Description: "info"})
+		l.E(Entry{Description: "error"})
+		l.(*logger).transports.close()
+
+		entries := []model.LogEntry{}
+		for msg := range producer.Successes() {
+			Expect(msg.Topic).To(Equal("test-topic"))
+			value, err := msg.Value.Encode()
+			Expect(err).ToNot(HaveOccurred())
+			entry := model.LogEntry{}
+			Expect(json.Unmarshal(value, &entry)).To(Succeed())
+			entries = append(entries, entry)
+		}
+		Expect(reporter.failures()).To(BeEmpty())
+		Expect(entries).To(Equal([]model.LogEntry{
+			{
+				Action:      "default-action",
+				Description: "error\n",
+				Level:       "ERROR",
+				ServiceName: "testsvc",
+			},
+		}))
+		Expect(output.String()).To(Equal("error\n"))
+	})
+
+	It("should send entries to provided transports without Kafka", func() {
+		transport := &mockTransport{}
+		l, err := InitWithOptions(
+			ctx,
+			"testsvc",
+			WithTransport(transport, TransportConfig{Name: "mock"}),
+			WithOutput(&bytes.Buffer{}),
+			WithLevel("INFO"),
+		)
+		Expect(err).ToNot(HaveOccurred())
+
+		l.I(Entry{Description: "info"})
+		l.D(Entry{Description: "debug"})
+		l.(*logger).transports.close()
+		Expect(transport.levels()).To(Equal([]string{"INFO"}))
+		Expect(transport.closed).To(BeTrue())
+	})
+})
@@ -0,0 +1,196 @@
+package log
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// The types below mirror the OTLP logs data-model (opentelemetry-proto v1).
+// JSON tags follow the OTLP/JSON mapping, and the protobuf encoding
+// is written by hand to avoid depending on generated code.
+
+type otlpExportRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name,omitempty"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         uint64         `json:"timeUnixNano,string"`
+	ObservedTimeUnixNano uint64         `json:"observedTimeUnixNano,string"`
+	SeverityNumber       int32          `json:"severityNumber,omitempty"`
+	SeverityText         string         `json:"severityText,omitempty"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+// otlpAnyValue holds either a string or an int value.
+type otlpAnyValue struct {
+	StringValue *string
+	IntValue    *int64
+}
+
+// MarshalJSON encodes IntValue as string, as required by OTLP/JSON for int64.
+func (v otlpAnyValue) MarshalJSON() ([]byte, error) {
+	m := map[string]string{}
+	if v.StringValue != nil {
+		m["stringValue"] = *v.StringValue
+	}
+	if v.IntValue != nil {
+		m["intValue"] = strconv.FormatInt(*v.IntValue, 10)
+	}
+	return json.Marshal(m)
+}
+
+func otlpString(s string) otlpAnyValue {
+	return otlpAnyValue{StringValue: &s}
+}
+
+func otlpInt(i int64) otlpAnyValue {
+	return otlpAnyValue{IntValue: &i}
+}
+
+// protobuf wire-types
+const (
+	pbVarint  = 0
+	pbFixed64 = 1
+	pbBytes   = 2
+)
+
+// pbBuffer is a minimal protobuf encoder.
+type pbBuffer []byte
+
+func (b *pbBuffer) tag(field int, wireType int) {
+	b.varint(uint64(field<<3 | wireType))
+}
+
+func (b *pbBuffer) varint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	*b = append(*b, buf[:n]...)
+}
+
+func (b *pbBuffer) varintField(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	b.tag(field, pbVarint)
+	b.varint(v)
+}
+
+func (b *pbBuffer) fixed64Field(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	b.tag(field, pbFixed64)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	*b = append(*b, buf[:]...)
+}
+
+func (b *pbBuffer) bytesField(field int, v []byte) {
+	b.tag(field, pbBytes)
+	b.varint(uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+func (b *pbBuffer) stringField(field int, v string) {
+	if v == "" {
+		return
+	}
+	b.bytesField(field, []byte(v))
+}
+
+// messageField encodes a nested message using the provided encode function.
+func (b *pbBuffer) messageField(field int, encode func(*pbBuffer)) {
+	nested := pbBuffer{}
+	encode(&nested)
+	b.bytesField(field, nested)
+}
+
+func (r otlpExportRequest) marshalProto() []byte {
+	b := pbBuffer{}
+	for _, rl := range r.ResourceLogs {
+		b.messageField(1, rl.encode)
+	}
+	return b
+}
+
+func (rl otlpResourceLogs) encode(b *pbBuffer) {
+	b.messageField(1, func(b *pbBuffer) {
+		for _, kv := range rl.Resource.Attributes {
+			b.messageField(1, kv.encode)
+		}
+	})
+	for _, sl := range rl.ScopeLogs {
+		b.messageField(2, sl.encode)
+	}
+}
+
+func (sl otlpScopeLogs) encode(b *pbBuffer) {
+	b.messageField(1, func(b *pbBuffer) {
+		b.stringField(1, sl.Scope.Name)
+	})
+	for _, lr := range sl.LogRecords {
+		b.messageField(2, lr.encode)
+	}
+}
+
+func (lr otlpLogRecord) encode(b *pbBuffer) {
+	b.fixed64Field(1, lr.TimeUnixNano)
+	b.varintField(2, uint64(lr.SeverityNumber))
+	b.stringField(3, lr.SeverityText)
+	b.messageField(5, lr.Body.encode)
+	for _, kv := range lr.Attributes {
+		b.messageField(6, kv.encode)
+	}
+	b.fixed64Field(11, lr.ObservedTimeUnixNano)
+}
+
+func (kv otlpKeyValue) encode(b *pbBuffer) {
+	b.stringField(1, kv.Key)
+	b.messageField(2, kv.Value.encode)
+}
+
+func (v otlpAnyValue) encode(b *pbBuffer) {
+	if v.StringValue != nil {
+		// Empty strings are still encoded, since this is a oneof field
+		b.bytesField(1, []byte(*v.StringValue))
+	}
+	if v.IntValue != nil {
+		b.tag(3, pbVarint)
+		b.varint(uint64(*v.IntValue))
+	}
+}
+
+// otlpTimeNano converts the time to OTLP's unsigned unix-nanoseconds,
+// which is zero for unknown time.
+func otlpTimeNano(t time.Time) uint64 {
+	if t.IsZero() || t.UnixNano() < 0 {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
@@ -0,0 +1,168 @@
+package log
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// OTLPEncoding is the payload-encoding for OTLP/HTTP.
+type OTLPEncoding int
+
+const (
+	// OTLPProtobuf encodes the payload as binary protobuf. This is the default.
+	OTLPProtobuf OTLPEncoding = iota
+	// OTLPJSON encodes the payload as OTLP/JSON.
+	OTLPJSON
+)
+
+// otlpScopeName is the instrumentation-scope for the exported log-records.
+const otlpScopeName = "github.com/TerrexTech/go-logtransport/log"
+
+// otlpSeverity maps log-levels to OTLP severity-numbers.
+var otlpSeverity = map[string]int32{
+	"DEBUG": 5,
+	"INFO":  9,
+	"ERROR": 17,
+}
+
+// OTLPConfig configures the OTLPTransport.
+type OTLPConfig struct {
+	// HTTPConfig configures the requests and batching.
+	// URL is the full logs endpoint, such as "http://localhost:4318/v1/logs".
+	HTTPConfig
+	// Encoding is the payload-encoding. Default is OTLPProtobuf.
+	Encoding OTLPEncoding
+	// ResourceAttributes are added to resource of every log-record,
+	// in addition to "service.name".
+	ResourceAttributes map[string]string
+}
+
+// OTLPTransport exports log-entries to an OpenTelemetry collector using OTLP/HTTP.
+// Entries are grouped into resources by their ServiceName.
+type OTLPTransport struct {
+	config OTLPConfig
+	sender *httpSender
+	batch  *batchSender
+}
+
+// NewOTLPTransport creates a Transport exporting batches of entries to config.URL.
+func NewOTLPTransport(config OTLPConfig) (*OTLPTransport, error) {
+	if config.Encoding != OTLPProtobuf && config.Encoding != OTLPJSON {
+		return nil, errors.Errorf("invalid OTLP encoding: %d", config.Encoding)
+	}
+	sender, bc, err := newHTTPSender(config.HTTPConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &OTLPTransport{
+		config: config,
+		sender: sender,
+	}
+	t.batch = newBatchSender(redactURL(config.URL), bc, t.send)
+	return t, nil
+}
+
+// exportRequest converts the Records to OTLP ExportLogsServiceRequest.
+func (t *OTLPTransport) exportRequest(records []*Record) otlpExportRequest {
+	observed := otlpTimeNano(time.Now())
+
+	services := []string{}
+	byService := map[string][]otlpLogRecord{}
+	for _, r := range records {
+		svc := r.Entry.ServiceName
+		if _, ok := byService[svc]; !ok {
+			services = append(services, svc)
+		}
+		byService[svc] = append(byService[svc], otlpRecord(r, observed))
+	}
+
+	attrKeys := make([]string, 0, len(t.config.ResourceAttributes))
+	for k := range t.config.ResourceAttributes {
+		attrKeys = append(attrKeys, k)
+	}
+	sort.Strings(attrKeys)
+
+	req := otlpExportRequest{}
+	for _, svc := range services {
+		attrs := []otlpKeyValue{}
+		if svc != "" {
+			attrs = append(attrs, otlpKeyValue{"service.name", otlpString(svc)})
+		}
+		for _, k := range attrKeys {
+			v := t.config.ResourceAttributes[k]
+			attrs = append(attrs, otlpKeyValue{k, otlpString(v)})
+		}
+
+		req.ResourceLogs = append(req.ResourceLogs, otlpResourceLogs{
+			Resource: otlpResource{Attributes: attrs},
+			ScopeLogs: []otlpScopeLogs{
+				{
+					Scope:      otlpScope{Name: otlpScopeName},
+					LogRecords: byService[svc],
+				},
+			},
+		})
+	}
+	return req
+}
+
+// otlpRecord converts the Record to OTLP LogRecord.
+func otlpRecord(r *Record, observed uint64) otlpLogRecord {
+	attrs := []otlpKeyValue{}
+	if r.Entry.Action != "" {
+		attrs = append(attrs, otlpKeyValue{"action", otlpString(r.Entry.Action)})
+	}
+	if r.Entry.ServiceName != "" {
+		attrs = append(attrs, otlpKeyValue{"serviceName", otlpString(r.Entry.ServiceName)})
+	}
+	if r.Entry.ErrorCode != 0 {
+		attrs = append(attrs, otlpKeyValue{"errorCode", otlpInt(int64(r.Entry.ErrorCode))})
+	}
+	if r.Caller != "" {
+		attrs = append(attrs, otlpKeyValue{"caller", otlpString(r.Caller)})
+	}
+	if len(r.Attachments) > 0 {
+		attrs = append(attrs, otlpKeyValue{
+			"attachments", otlpString(attachmentsJSON(r.Attachments)),
+		})
+	}
+
+	return otlpLogRecord{
+		TimeUnixNano:         otlpTimeNano(r.Time),
+		ObservedTimeUnixNano: observed,
+		SeverityNumber:       otlpSeverity[r.Entry.Level],
+		SeverityText:         r.Entry.Level,
+		Body:                 otlpString(strings.TrimRight(r.Entry.Description, "\n")),
+		Attributes:           attrs,
+	}
+}
+
+func (t *OTLPTransport) send(records []*Record) error {
+	req := t.exportRequest(records)
+	if t.config.Encoding == OTLPJSON {
+		body, err := json.Marshal(req)
+		if err != nil {
+			err = errors.Wrap(err, "Error marshalling OTLP request")
+			return err
+		}
+		return t.sender.send(body, ContentTypeJSON)
+	}
+	return t.sender.send(req.marshalProto(), "application/x-protobuf")
+}
+
+// Write adds the Record to current batch.
+func (t *OTLPTransport) Write(r *Record) error {
+	t.batch.add(r)
+	return nil
+}
+
+// Close exports the remaining entries and waits for requests in flight.
+func (t *OTLPTransport) Close() error {
+	t.sender.closeAfter(t.batch.close)
+	return nil
+}
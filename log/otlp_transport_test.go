@@ -0,0 +1,229 @@
+package log
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/TerrexTech/go-common-models/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// pbFields decodes a protobuf message into its fields, keyed by field-number.
+// Varint and fixed64 values are decoded as uint64, and bytes as []byte.
+func pbFields(b []byte) map[int][]interface{} {
+	fields := map[int][]interface{}{}
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		Expect(n).To(BeNumerically(">", 0))
+		b = b[n:]
+
+		field := int(tag >> 3)
+		switch tag & 7 {
+		case pbVarint:
+			v, n := binary.Uvarint(b)
+			Expect(n).To(BeNumerically(">", 0))
+			fields[field] = append(fields[field], v)
+			b = b[n:]
+		case pbFixed64:
+			fields[field] = append(fields[field], binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		case pbBytes:
+			l, n := binary.Uvarint(b)
+			Expect(n).To(BeNumerically(">", 0))
+			b = b[n:]
+			fields[field] = append(fields[field], b[:l])
+			b = b[l:]
+		default:
+			Fail("unexpected wire-type")
+		}
+	}
+	return fields
+}
+
+// pbKeyValues decodes repeated KeyValue fields with string or int values.
+func pbKeyValues(kvs []interface{}) map[string]interface{} {
+	m := map[string]interface{}{}
+	for _, kv := range kvs {
+		f := pbFields(kv.([]byte))
+		value := pbFields(f[2][0].([]byte))
+		key := string(f[1][0].([]byte))
+		if s, ok := value[1]; ok {
+			m[key] = string(s[0].([]byte))
+		} else {
+			m[key] = int64(value[3][0].(uint64))
+		}
+	}
+	return m
+}
+
+var _ = Describe("OTLPTransport", func() {
+	var (
+		server   *httptest.Server
+		reqChan  chan []byte
+		ctypes   chan string
+		logTime  time.Time
+		testRecs []*Record
+	)
+
+	BeforeEach(func() {
+		reqChan = make(chan []byte, 10)
+		ctypes = make(chan string, 10)
+		server = httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				defer GinkgoRecover()
+				Expect(r.URL.Path).To(Equal("/v1/logs"))
+				b, err := ioutil.ReadAll(r.Body)
+				Expect(err).ToNot(HaveOccurred())
+				ctypes <- r.Header.Get("Content-Type")
+				reqChan <- b
+			},
+		))
+
+		logTime = time.Unix(1541795750, 0)
+		testRecs = []*Record{
+			{
+				Entry: model.LogEntry{
+					Action:      "test-action",
+					Description: "test-description\n",
+					ErrorCode:   4,
+					Level:       "ERROR",
+					ServiceName: "testsvc",
+				},
+				Time: logTime,
+			},
+			{
+				Entry: model.LogEntry{
+					Description: "other-description\n",
+					Level:       "DEBUG",
+					ServiceName: "othersvc",
+				},
+				Time: logTime,
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should export log-records as protobuf", func() {
+		t, err := NewOTLPTransport(OTLPConfig{
+			HTTPConfig: HTTPConfig{
+				URL: server.URL + "/v1/logs",
+			},
+			ResourceAttributes: map[string]string{
+				"deployment.environment": "test",
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		for _, r := range testRecs {
+			Expect(t.Write(r)).To(Succeed())
+		}
+		Expect(t.Close()).To(Succeed())
+
+		Expect(<-ctypes).To(Equal("application/x-protobuf"))
+		req := pbFields(<-reqChan)
+		Expect(req[1]).To(HaveLen(2))
+
+		resourceLogs := pbFields(req[1][0].([]byte))
+		resource := pbFields(resourceLogs[1][0].([]byte))
+		Expect(pbKeyValues(resource[1])).To(Equal(map[string]interface{}{
+			"service.name":           "testsvc",
+			"deployment.environment": "test",
+		}))
+
+		scopeLogs := pbFields(resourceLogs[2][0].([]byte))
+		scope := pbFields(scopeLogs[1][0].([]byte))
+		Expect(string(scope[1][0].([]byte))).To(Equal(otlpScopeName))
+
+		logRecord := pbFields(scopeLogs[2][0].([]byte))
+		Expect(logRecord[1][0]).To(Equal(uint64(logTime.UnixNano())))
+		Expect(logRecord[2][0]).To(Equal(uint64(17)))
+		Expect(string(logRecord[3][0].([]byte))).To(Equal("ERROR"))
+		body := pbFields(logRecord[5][0].([]byte))
+		Expect(string(body[1][0].([]byte))).To(Equal("test-description"))
+		Expect(pbKeyValues(logRecord[6])).To(Equal(map[string]interface{}{
+			"action":      "test-action",
+			"serviceName": "testsvc",
+			"errorCode":   int64(4),
+		}))
+		Expect(logRecord[11]).To(HaveLen(1))
+	})
+
+	It("should export log-records as JSON", func() {
+		t, err := NewOTLPTransport(OTLPConfig{
+			HTTPConfig: HTTPConfig{
+				URL: server.URL + "/v1/logs",
+			},
+			Encoding: OTLPJSON,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		for _, r := range testRecs {
+			Expect(t.Write(r)).To(Succeed())
+		}
+		Expect(t.Close()).To(Succeed())
+
+		Expect(<-ctypes).To(Equal(ContentTypeJSON))
+		req := map[string]interface{}{}
+		err = json.Unmarshal(<-reqChan, &req)
+		Expect(err).ToNot(HaveOccurred())
+
+		resourceLogs := req["resourceLogs"].([]interface{})
+		Expect(resourceLogs).To(HaveLen(2))
+		rl := resourceLogs[1].(map[string]interface{})
+		Expect(rl["resource"]).To(Equal(map[string]interface{}{
+			"attributes": []interface{}{
+				map[string]interface{}{
+					"key":   "service.name",
+					"value": map[string]interface{}{"stringValue": "othersvc"},
+				},
+			},
+		}))
+
+		scopeLogs := rl["scopeLogs"].([]interface{})[0].(map[string]interface{})
+		logRecord := scopeLogs["logRecords"].([]interface{})[0].(map[string]interface{})
+		Expect(logRecord["timeUnixNano"]).To(Equal("1541795750000000000"))
+		Expect(logRecord["severityNumber"]).To(BeEquivalentTo(5))
+		Expect(logRecord["severityText"]).To(Equal("DEBUG"))
+		Expect(logRecord["body"]).To(Equal(map[string]interface{}{
+			"stringValue": "other-description",
+		}))
+	})
+
+	It("should add the caller and attachments at DEBUG level as attributes", func() {
+		r := testRecs[1]
+		r.Caller = "test.go:12"
+		r.Attachments = []Attachment{
+			{Type: "string", Value: json.RawMessage(`"test-data"`)},
+		}
+		lr := otlpRecord(r, 0)
+		Expect(lr.Attributes).To(ContainElement(
+			otlpKeyValue{"caller", otlpString("test.go:12")},
+		))
+		Expect(lr.Attributes).To(ContainElement(otlpKeyValue{
+			"attachments",
+			otlpString(`[{"index":0,"type":"string","value":"test-data"}]`),
+		}))
+	})
+
+	It("should encode int attributes as strings in JSON", func() {
+		b, err := json.Marshal(otlpInt(4))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(b)).To(Equal(`{"intValue":"4"}`))
+	})
+
+	It("should return error on invalid encoding", func() {
+		_, err := NewOTLPTransport(OTLPConfig{
+			HTTPConfig: HTTPConfig{
+				URL: server.URL,
+			},
+			Encoding: 5,
+		})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,363 @@
+package log
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/TerrexTech/go-common-models/model"
+	"github.com/pkg/errors"
+)
+
+// MaskMode is how a sensitive value is masked.
+type MaskMode string
+
+// Masking modes for RedactionRule and struct-tags.
+const (
+	// MaskFull replaces the value with "[REDACTED]".
+	MaskFull MaskMode = "full"
+	// MaskPartial keeps the last 4 characters of strings longer than 8
+	// characters, such as "************1234", and masks the rest.
+	MaskPartial MaskMode = "partial"
+	// MaskHash replaces the value with the prefix of its SHA-256 hash, such as
+	// "sha256:9f86d081884c7d65", so equal values can still be correlated.
+	// Values with few possibilities, such as PINs, can be recovered from hashes.
+	MaskHash MaskMode = "hash"
+)
+
+// validate checks if the mode is one of the masking modes, or empty.
+func (m MaskMode) validate() error {
+	switch m {
+	case "", MaskFull, MaskPartial, MaskHash:
+		return nil
+	}
+	return errors.Errorf("invalid Mask %s, valid masks are: full, partial and hash", m)
+}
+
+// redactedValue replaces the values masked using MaskFull.
+const redactedValue = "[REDACTED]"
+
+// RedactionRule masks sensitive data in attachments, and the log-entry's
+// Action, Description and ServiceName, before the entry is written or sent
+// anywhere. Level and ErrorCode are never masked, since entries are routed
+// by them. Either Key or Value must be set. Struct-fields tagged
+// `log:"redact"` are always masked, and the mode can be set as in
+// `log:"redact,partial"`. Fields tagged with an invalid mode are masked fully.
+type RedactionRule struct {
+	// Key is a case-insensitive pattern, as in path.Match, for the keys of
+	// objects whose values are masked, such as "password" or "*token*".
+	// This applies to attachments, including the parsed Data of Common-Models,
+	// and to the log-entry's fields by their JSON keys, such as "action".
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+	// Value is a regular-expression, as in regexp.Compile, matching the parts
+	// of strings to be masked, such as email-addresses or card-numbers.
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+	// Mask is how the matched values are masked. Default is MaskFull.
+	Mask MaskMode `json:"mask,omitempty" yaml:"mask,omitempty"`
+}
+
+func (r *RedactionRule) validate() error {
+	switch {
+	case r.Key == "" && r.Value == "":
+		return errors.New("either Key or Value is required")
+	case r.Key != "" && r.Value != "":
+		return errors.New("only one of Key and Value can be set")
+	}
+	if err := r.Mask.validate(); err != nil {
+		return err
+	}
+	if r.Key != "" {
+		if _, err := path.Match(r.Key, ""); err != nil {
+			return errors.Wrapf(err, "invalid Key pattern %s", r.Key)
+		}
+	}
+	if r.Value != "" {
+		if _, err := regexp.Compile(r.Value); err != nil {
+			return errors.Wrapf(err, "invalid Value regular-expression %s", r.Value)
+		}
+	}
+	return nil
+}
+
+// redactor applies the RedactionRules and the redact struct-tags.
+type redactor struct {
+	keyRules   []RedactionRule
+	valueRules []*regexp.Regexp
+	valueMasks []MaskMode
+}
+
+// newRedactor validates and compiles the rules.
+func newRedactor(rules []RedactionRule) (*redactor, error) {
+	r := &redactor{}
+	for i, rule := range rules {
+		err := rule.validate()
+		if err != nil {
+			err = errors.Wrapf(err, "rule %d", i)
+			return nil, err
+		}
+		if rule.Key != "" {
+			rule.Key = strings.ToLower(rule.Key)
+			r.keyRules = append(r.keyRules, rule)
+			continue
+		}
+		r.valueRules = append(r.valueRules, regexp.MustCompile(rule.Value))
+		r.valueMasks = append(r.valueMasks, rule.Mask)
+	}
+	return r, nil
+}
+
+// redactString masks the parts of s matching the Value rules.
+func (r *redactor) redactString(s string, redacted *bool) string {
+	if r == nil {
+		return s
+	}
+	for i, re := range r.valueRules {
+		mask := r.valueMasks[i]
+		s = re.ReplaceAllStringFunc(s, func(match string) string {
+			*redacted = true
+			return maskString(match, mask)
+		})
+	}
+	return s
+}
+
+// redactValue applies the rules to a decoded JSON value.
+func (r *redactor) redactValue(value interface{}, redacted *bool) interface{} {
+	if r == nil {
+		return value
+	}
+	switch t := value.(type) {
+	case string:
+		return r.redactString(t, redacted)
+	case []interface{}:
+		for i := range t {
+			t[i] = r.redactValue(t[i], redacted)
+		}
+	case map[string]interface{}:
+		for k, v := range t {
+			if mask, ok := r.keyMask(k); ok {
+				t[k] = maskValue(v, mask)
+				*redacted = true
+				continue
+			}
+			t[k] = r.redactValue(v, redacted)
+		}
+	}
+	return value
+}
+
+// redactEntry applies the rules to the log-entry's Action, Description and
+// ServiceName, see RedactionRule.
+func (r *redactor) redactEntry(entry *model.LogEntry, redacted *bool) {
+	if r == nil {
+		return
+	}
+	fields := map[string]*string{
+		"action":      &entry.Action,
+		"description": &entry.Description,
+		"serviceName": &entry.ServiceName,
+	}
+	for key, field := range fields {
+		if *field == "" {
+			continue
+		}
+		if mask, ok := r.keyMask(key); ok {
+			*field = maskString(*field, mask)
+			*redacted = true
+			continue
+		}
+		*field = r.redactString(*field, redacted)
+	}
+}
+
+// keyMask returns the mask of first Key rule matching key.
+func (r *redactor) keyMask(key string) (MaskMode, bool) {
+	key = strings.ToLower(key)
+	for _, rule := range r.keyRules {
+		if ok, _ := path.Match(rule.Key, key); ok {
+			return rule.Mask, true
+		}
+	}
+	return "", false
+}
+
+// redactTagged masks the fields tagged `log:"redact"` in the decoded
+// JSON value of v.
+func redactTagged(v reflect.Value, value interface{}, redacted *bool) interface{} {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return value
+		}
+		v = v.Elem()
+	}
+	if !hasRedactTags(v.Type()) {
+		return value
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if m, ok := value.(map[string]interface{}); ok {
+			redactFields(v, m, redacted)
+		}
+	case reflect.Slice, reflect.Array:
+		if arr, ok := value.([]interface{}); ok && len(arr) == v.Len() {
+			for i := range arr {
+				arr[i] = redactTagged(v.Index(i), arr[i], redacted)
+			}
+		}
+	case reflect.Map:
+		if m, ok := value.(map[string]interface{}); ok {
+			for _, k := range v.MapKeys() {
+				key, ok := mapKeyString(k)
+				if mv, exists := m[key]; ok && exists {
+					m[key] = redactTagged(v.MapIndex(k), mv, redacted)
+				}
+			}
+		}
+	}
+	return value
+}
+
+// redactFields masks the tagged fields of struct v in its decoded JSON object.
+func redactFields(v reflect.Value, m map[string]interface{}, redacted *bool) {
+	walkJSONFields(v, func(name string, f reflect.StructField, fv reflect.Value) {
+		value, ok := m[name]
+		if !ok {
+			return
+		}
+		if mask, ok := redactTag(f); ok {
+			m[name] = maskValue(value, mask)
+			*redacted = true
+			return
+		}
+		m[name] = redactTagged(fv, value, redacted)
+	})
+}
+
+// mapKeyString returns the JSON object-key of map-key k, as encoding/json does.
+func mapKeyString(k reflect.Value) (string, bool) {
+	if k.Kind() == reflect.String {
+		return k.String(), true
+	}
+	// Keys of maps in unexported embedded structs cannot be accessed
+	if !k.CanInterface() {
+		return "", false
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		text, err := tm.MarshalText()
+		return string(text), err == nil
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(k.Uint(), 10), true
+	}
+	return "", false
+}
+
+// redactTag returns the mask of field tagged as `log:"redact"` or
+// `log:"redact,<mask>"`. Invalid masks are logged once, and MaskFull is used,
+// so the value is not leaked.
+func redactTag(f reflect.StructField) (MaskMode, bool) {
+	tag := f.Tag.Get("log")
+	parts := strings.Split(tag, ",")
+	if parts[0] != "redact" {
+		return "", false
+	}
+	mask := MaskFull
+	if len(parts) > 1 && parts[1] != "" {
+		mask = MaskMode(strings.Join(parts[1:], ","))
+	}
+	if err := mask.validate(); err != nil {
+		if _, reported := invalidRedactTags.LoadOrStore(f.Name+" "+tag, true); !reported {
+			log.Printf("LogTransport: field %s has invalid redact-tag: %s", f.Name, err)
+		}
+		return MaskFull, true
+	}
+	return mask, true
+}
+
+// invalidRedactTags are the invalid redact-tags which were logged.
+var invalidRedactTags sync.Map
+
+// redactTypes caches if types contain fields tagged as redact.
+var redactTypes sync.Map
+
+// hasRedactTags checks if values of type t can contain fields tagged as redact.
+func hasRedactTags(t reflect.Type) bool {
+	if has, ok := redactTypes.Load(t); ok {
+		return has.(bool)
+	}
+	// Only the result for t is cached, since results for nested types
+	// are incomplete for recursive types.
+	has := typeHasRedactTags(t, map[reflect.Type]bool{})
+	redactTypes.Store(t, has)
+	return has
+}
+
+func typeHasRedactTags(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Interface:
+		// Dynamic values are checked when redacting
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return typeHasRedactTags(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if _, ok := redactTag(f); ok || typeHasRedactTags(f.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// maskValue masks a decoded JSON value. Values other than strings are
+// masked fully, unless MaskHash is used. Null is kept as is.
+func maskValue(value interface{}, mask MaskMode) interface{} {
+	switch t := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return maskString(t, mask)
+	default:
+		if mask != MaskHash {
+			return redactedValue
+		}
+		ml, _ := json.Marshal(t)
+		return maskString(string(ml), mask)
+	}
+}
+
+func maskString(s string, mask MaskMode) string {
+	switch mask {
+	case MaskPartial:
+		n := utf8.RuneCountInString(s)
+		if n <= 8 {
+			return strings.Repeat("*", n)
+		}
+		runes := []rune(s)
+		return strings.Repeat("*", n-4) + string(runes[n-4:])
+	case MaskHash:
+		sum := sha256.Sum256([]byte(s))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	default:
+		return redactedValue
+	}
+}
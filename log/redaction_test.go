@@ -0,0 +1,234 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/TerrexTech/go-common-models/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Redaction", func() {
+	type credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password" log:"redact"`
+		Card     string `json:"card" log:"redact,partial"`
+		Email    string `log:"redact,hash"`
+	}
+	type Embedded struct {
+		Token string `json:"token" log:"redact"`
+	}
+	type account struct {
+		Embedded
+		Name        string               `json:"name"`
+		Credentials []credentials        `json:"credentials"`
+		ByID        map[int]*credentials `json:"byId"`
+		Extra       interface{}          `json:"extra"`
+		Ignored     string               `json:"-" log:"redact"`
+	}
+
+	// decode returns the decoded Value of attachment.
+	decode := func(a Attachment) interface{} {
+		var value interface{}
+		Expect(json.Unmarshal(a.Value, &value)).To(Succeed())
+		return value
+	}
+
+	It("should mask struct-fields tagged as redact", func() {
+		creds := credentials{
+			Username: "user",
+			Password: "secret",
+			Card:     "4111111111111111",
+			Email:    "user@example.com",
+		}
+		a := newAttachment(0, &account{
+			Embedded:    Embedded{Token: "token"},
+			Name:        "test",
+			Credentials: []credentials{creds},
+			ByID:        map[int]*credentials{4: &creds},
+			Extra:       creds,
+		}, DefaultFormatLimits, nil)
+		Expect(a.Redacted).To(BeTrue())
+
+		masked := map[string]interface{}{
+			"username": "user",
+			"password": "[REDACTED]",
+			"card":     "************1111",
+			"Email":    "sha256:b4c9a289323b21a0",
+		}
+		Expect(decode(a)).To(Equal(map[string]interface{}{
+			"token":       "[REDACTED]",
+			"name":        "test",
+			"credentials": []interface{}{masked},
+			"byId":        map[string]interface{}{"4": masked},
+			"extra":       masked,
+		}))
+
+		a = newAttachment(0, model.EventMeta{AggregateID: 1}, DefaultFormatLimits, nil)
+		Expect(a.Redacted).To(BeFalse())
+	})
+
+	It("should mask values of matching keys", func() {
+		r, err := newRedactor([]RedactionRule{
+			{Key: "password"},
+			{Key: "*token*", Mask: MaskHash},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		a := newAttachment(0, model.Command{
+			Action: "login",
+			Data: []byte(
+				`{"user":{"Password":"secret","accessToken":"abc"},"pin":1234}`,
+			),
+		}, DefaultFormatLimits, r)
+		Expect(a.Redacted).To(BeTrue())
+		value := decode(a).(map[string]interface{})
+		Expect(value["data"]).To(Equal(map[string]interface{}{
+			"user": map[string]interface{}{
+				"Password":    "[REDACTED]",
+				"accessToken": "sha256:ba7816bf8f01cfea",
+			},
+			"pin": 1234.0,
+		}))
+	})
+
+	It("should mask parts of strings matching values", func() {
+		r, err := newRedactor([]RedactionRule{
+			{Value: `[\w.]+@[\w.]+`},
+			{Value: `\b\d{16}\b`, Mask: MaskPartial},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		a := newAttachment(0, map[string]interface{}{
+			"note": "mail user@example.com, card 4111111111111111",
+			"list": []string{"a@b.c"},
+		}, DefaultFormatLimits, r)
+		Expect(decode(a)).To(Equal(map[string]interface{}{
+			"note": "mail [REDACTED], card ************1111",
+			"list": []interface{}{"[REDACTED]"},
+		}))
+	})
+
+	It("should redact before applying limits", func() {
+		r, err := newRedactor([]RedactionRule{{Value: "secret-value"}})
+		Expect(err).ToNot(HaveOccurred())
+		a := newAttachment(0, "secret-value", FormatLimits{MaxStringLength: 8}, r)
+		Expect(a.Redacted).To(BeTrue())
+		Expect(a.Truncated).To(BeTrue())
+		Expect(decode(a)).To(Equal("[REDACTE...[truncated 2 bytes]"))
+	})
+
+	It("should mask descriptions and attachments of logged entries", func() {
+		output := &bytes.Buffer{}
+		mock := &mockTransport{}
+		l, err := InitWithOptions(
+			context.Background(),
+			"testsvc",
+			WithLevel("DEBUG"),
+			WithOutput(output),
+			WithFormatter(JSONFormatter{}),
+			WithTransport(mock, TransportConfig{Name: "mock"}),
+			WithRedaction(RedactionRule{Value: `[\w.]+@[\w.]+`}),
+		)
+		Expect(err).ToNot(HaveOccurred())
+
+		l.D(Entry{Description: "login by user@example.com"}, credentials{
+			Username: "user@example.com",
+			Password: "secret",
+		})
+		l.(*logger).transports.close()
+
+		Expect(mock.records).To(HaveLen(1))
+		r := mock.records[0]
+		Expect(r.Entry.Description).To(Equal("login by [REDACTED]\n"))
+		Expect(r.Attachments[0].Redacted).To(BeTrue())
+		Expect(string(r.Attachments[0].Value)).ToNot(ContainSubstring("example.com"))
+		Expect(string(r.Attachments[0].Value)).ToNot(ContainSubstring("secret"))
+		Expect(output.String()).ToNot(ContainSubstring("example.com"))
+
+		Expect(l.SetRedaction(RedactionRule{Key: "["})).To(HaveOccurred())
+	})
+
+	It("should mask the entry fields by key and value", func() {
+		r, err := newRedactor([]RedactionRule{
+			{Key: "action", Mask: MaskPartial},
+			{Value: `\d{4}-\d{4}`},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		redacted := false
+		entry := model.LogEntry{
+			Action:      "transfer-1234",
+			Description: "card 4111-1111",
+			ErrorCode:   1234,
+			Level:       "INFO",
+			ServiceName: "svc-1234-5678",
+		}
+		r.redactEntry(&entry, &redacted)
+		Expect(redacted).To(BeTrue())
+		Expect(entry).To(Equal(model.LogEntry{
+			Action:      "*********1234",
+			Description: "card [REDACTED]",
+			ErrorCode:   1234,
+			Level:       "INFO",
+			ServiceName: "svc-[REDACTED]",
+		}))
+	})
+
+	It("should mask fields with invalid redact-tags fully", func() {
+		type invalidTag struct {
+			Card  string `json:"card" log:"redact,stars"`
+			Token string `json:"token" log:"redact,"`
+		}
+		a := newAttachment(0, invalidTag{"4111111111111111", "token"}, DefaultFormatLimits, nil)
+		Expect(decode(a)).To(Equal(map[string]interface{}{
+			"card":  "[REDACTED]",
+			"token": "[REDACTED]",
+		}))
+	})
+
+	It("should omit values with redact-tags which cannot be marshalled", func() {
+		type unserializable struct {
+			User     string
+			Password string `log:"redact"`
+			C        chan int
+		}
+		d := unserializable{User: "u", Password: "hunter2", C: make(chan int)}
+		a := newAttachment(0, d, DefaultFormatLimits, nil)
+		Expect(a.Error).ToNot(BeEmpty())
+		Expect(a.Redacted).To(BeTrue())
+		Expect(decode(a)).To(Equal("[unserializable log.unserializable]"))
+
+		a = newAttachment(0, map[string]interface{}{
+			"creds": credentials{Password: "hunter2"},
+			"fn":    func() {},
+		}, DefaultFormatLimits, nil)
+		Expect(a.Error).ToNot(BeEmpty())
+		Expect(string(a.Value)).ToNot(ContainSubstring("hunter2"))
+
+		// Values without redact-tags are still formatted
+		a = newAttachment(0, struct{ C chan int }{}, DefaultFormatLimits, nil)
+		Expect(a.Redacted).To(BeFalse())
+		Expect(decode(a)).To(Equal("{C:<nil>}"))
+	})
+
+	It("should return error for invalid rules", func() {
+		cases := []RedactionRule{
+			{},
+			{Key: "password", Value: "secret"},
+			{Key: "password", Mask: "stars"},
+			{Key: "["},
+			{Value: "("},
+		}
+		for _, c := range cases {
+			_, err := newRedactor([]RedactionRule{c})
+			Expect(err).To(HaveOccurred())
+
+			_, err = InitWithOptions(context.Background(), "testsvc", WithRedaction(c))
+			Expect(err).To(BeAssignableToTypeOf(&ConfigError{}))
+			Expect(err.(*ConfigError).Field).To(Equal("Redaction"))
+		}
+	})
+})
@@ -0,0 +1,175 @@
+package log
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"strconv"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+)
+
+// scramClient performs the client-side of SCRAM authentication, as per
+// RFC 5802, for SCRAM-SHA-256 and SCRAM-SHA-512 SASL mechanisms.
+// Usernames and passwords are used as is, without SASLprep normalization.
+type scramClient struct {
+	hash func() hash.Hash
+
+	username string
+	password string
+	authzID  string
+	nonce    string
+
+	step            int
+	gs2Header       string
+	clientFirstBare string
+	serverSignature []byte
+}
+
+func newSCRAMClient(mechanism string) *scramClient {
+	h := sha256.New
+	if mechanism == sarama.SASLTypeSCRAMSHA512 {
+		h = sha512.New
+	}
+	return &scramClient{hash: h}
+}
+
+// Begin prepares the client for a new SCRAM exchange.
+func (c *scramClient) Begin(username, password, authzID string) error {
+	nonce := make([]byte, 24)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		err = errors.Wrap(err, "Error generating SCRAM nonce")
+		return err
+	}
+
+	c.username = username
+	c.password = password
+	c.authzID = authzID
+	c.nonce = base64.RawStdEncoding.EncodeToString(nonce)
+	c.step = 0
+	return nil
+}
+
+// Step returns the response to server's challenge.
+func (c *scramClient) Step(challenge string) (string, error) {
+	c.step++
+	switch c.step {
+	case 1:
+		return c.clientFirst(), nil
+	case 2:
+		return c.clientFinal(challenge)
+	case 3:
+		return "", c.verifyServerFinal(challenge)
+	}
+	return "", errors.New("SCRAM exchange is already done")
+}
+
+// Done checks if the server's signature has been verified.
+func (c *scramClient) Done() bool {
+	return c.step >= 3
+}
+
+func (c *scramClient) clientFirst() string {
+	c.gs2Header = "n,"
+	if c.authzID != "" {
+		c.gs2Header += "a=" + scramEscape(c.authzID)
+	}
+	c.gs2Header += ","
+	c.clientFirstBare = "n=" + scramEscape(c.username) + ",r=" + c.nonce
+	return c.gs2Header + c.clientFirstBare
+}
+
+func (c *scramClient) clientFinal(serverFirst string) (string, error) {
+	attrs := parseSCRAMAttributes(serverFirst)
+	nonce := attrs["r"]
+	if !strings.HasPrefix(nonce, c.nonce) || len(nonce) == len(c.nonce) {
+		return "", errors.New("invalid nonce in SCRAM server-first message")
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil || len(salt) == 0 {
+		return "", errors.New("invalid salt in SCRAM server-first message")
+	}
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations < 1 {
+		return "", errors.New("invalid iteration-count in SCRAM server-first message")
+	}
+
+	saltedPassword := pbkdf2Key(c.hash, []byte(c.password), salt, iterations)
+	clientKey := c.hmac(saltedPassword, "Client Key")
+	storedKey := c.hash()
+	storedKey.Write(clientKey)
+
+	withoutProof := "c=" + base64.StdEncoding.EncodeToString([]byte(c.gs2Header)) +
+		",r=" + nonce
+	authMessage := c.clientFirstBare + "," + serverFirst + "," + withoutProof
+
+	proof := c.hmac(storedKey.Sum(nil), authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	c.serverSignature = c.hmac(c.hmac(saltedPassword, "Server Key"), authMessage)
+
+	return withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+func (c *scramClient) verifyServerFinal(serverFinal string) error {
+	attrs := parseSCRAMAttributes(serverFinal)
+	if e, ok := attrs["e"]; ok {
+		return errors.Errorf("SCRAM authentication failed: %s", e)
+	}
+	signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || !hmac.Equal(signature, c.serverSignature) {
+		return errors.New("invalid server-signature in SCRAM server-final message")
+	}
+	return nil
+}
+
+func (c *scramClient) hmac(key []byte, message string) []byte {
+	mac := hmac.New(c.hash, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// pbkdf2Key derives a key of hash's size, as per PBKDF2 in RFC 8018.
+func pbkdf2Key(h func() hash.Hash, password, salt []byte, iterations int) []byte {
+	prf := hmac.New(h, password)
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1})
+	u := prf.Sum(nil)
+
+	key := make([]byte, len(u))
+	copy(key, u)
+	for n := 1; n < iterations; n++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for i := range key {
+			key[i] ^= u[i]
+		}
+	}
+	return key
+}
+
+// scramEscape escapes the characters which are not allowed in SCRAM usernames.
+func scramEscape(s string) string {
+	s = strings.Replace(s, "=", "=3D", -1)
+	return strings.Replace(s, ",", "=2C", -1)
+}
+
+// parseSCRAMAttributes parses the comma-separated attributes of SCRAM messages,
+// such as "r=nonce,s=salt,i=4096".
+func parseSCRAMAttributes(message string) map[string]string {
+	attrs := map[string]string{}
+	for _, attr := range strings.Split(message, ",") {
+		if len(attr) >= 2 && attr[1] == '=' {
+			attrs[attr[:1]] = attr[2:]
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,236 @@
+package log
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Syslog facilities as defined in RFC 5424.
+const (
+	FacilityUser   = 1
+	FacilityDaemon = 3
+	FacilityLocal0 = 16
+	FacilityLocal1 = 17
+	FacilityLocal2 = 18
+	FacilityLocal3 = 19
+	FacilityLocal4 = 20
+	FacilityLocal5 = 21
+	FacilityLocal6 = 22
+	FacilityLocal7 = 23
+)
+
+// DefaultSyslogSDID is the default SD-ID for structured-data in syslog messages.
+// 32473 is the Private Enterprise Number reserved for documentation.
+const DefaultSyslogSDID = "logtransport@32473"
+
+// syslogSeverity maps log-levels to syslog severities.
+var syslogSeverity = map[string]int{
+	"ERROR": 3,
+	"INFO":  6,
+	"DEBUG": 7,
+}
+
+// syslogNil is the NILVALUE for empty syslog-header fields.
+const syslogNil = "-"
+
+// SyslogConfig configures the SyslogTransport.
+type SyslogConfig struct {
+	// Network is one of "udp", "tcp" or "tls".
+	Network string
+	// Address is the host:port of syslog collector.
+	Address string
+	// TLSConfig is used for "tls" network.
+	TLSConfig *tls.Config
+	// Facility is the syslog facility. Default is FacilityUser.
+	Facility int
+	// Hostname is the HOSTNAME in syslog-header. Default is os.Hostname.
+	Hostname string
+	// SDID is the SD-ID for structured-data. Default is DefaultSyslogSDID.
+	SDID string
+	// StructuredData are additional static SD-PARAMs added to every message.
+	StructuredData map[string]string
+	// DialTimeout is the timeout for connecting to collector. Default is 5 seconds.
+	DialTimeout time.Duration
+	// WriteTimeout is the timeout for writing a message. Default is 5 seconds.
+	WriteTimeout time.Duration
+	// MaxReconnects is the number of reconnect-attempts when writing a message
+	// fails. Default is 1.
+	MaxReconnects int
+}
+
+// SyslogTransport sends log-entries to a syslog collector as RFC 5424 messages.
+// Messages are framed using octet-counting (RFC 6587) over TCP and TLS.
+type SyslogTransport struct {
+	config SyslogConfig
+	procID string
+	conn   *reconnectingConn
+}
+
+// NewSyslogTransport creates a Transport sending to syslog collector.
+// The connection is established lazily on first write.
+func NewSyslogTransport(config SyslogConfig) (*SyslogTransport, error) {
+	switch config.Network {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, errors.Errorf("invalid syslog network: %s", config.Network)
+	}
+	if config.Address == "" {
+		return nil, errors.New("empty syslog address provided")
+	}
+	if config.Facility < 0 || config.Facility > 23 {
+		return nil, errors.Errorf("invalid syslog facility: %d", config.Facility)
+	}
+	if config.Facility == 0 {
+		config.Facility = FacilityUser
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+	if config.SDID == "" {
+		config.SDID = DefaultSyslogSDID
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = 5 * time.Second
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = 5 * time.Second
+	}
+	if config.MaxReconnects <= 0 {
+		config.MaxReconnects = 1
+	}
+
+	return &SyslogTransport{
+		config: config,
+		procID: strconv.Itoa(os.Getpid()),
+		conn: &reconnectingConn{
+			network:       config.Network,
+			address:       config.Address,
+			tlsConfig:     config.TLSConfig,
+			dialTimeout:   config.DialTimeout,
+			writeTimeout:  config.WriteTimeout,
+			maxReconnects: config.MaxReconnects,
+		},
+	}, nil
+}
+
+// Write sends the Record as syslog message, reconnecting if the write fails.
+func (t *SyslogTransport) Write(r *Record) error {
+	err := t.conn.write(t.frame(t.format(r)))
+	if err != nil {
+		err = errors.Wrap(err, "Error writing syslog message")
+	}
+	return err
+}
+
+// frame applies octet-counting framing for stream-based networks.
+func (t *SyslogTransport) frame(msg string) []byte {
+	if t.config.Network == "udp" {
+		return []byte(msg)
+	}
+	return []byte(strconv.Itoa(len(msg)) + " " + msg)
+}
+
+// format renders the Record as RFC 5424 syslog message.
+func (t *SyslogTransport) format(r *Record) string {
+	severity, ok := syslogSeverity[r.Entry.Level]
+	if !ok {
+		severity = syslogSeverity["INFO"]
+	}
+	pri := t.config.Facility*8 + severity
+
+	timestamp := syslogNil
+	if !r.Time.IsZero() {
+		timestamp = r.Time.Format(time.RFC3339Nano)
+	}
+
+	return fmt.Sprintf(
+		"<%d>1 %s %s %s %s %s %s %s",
+		pri,
+		timestamp,
+		syslogHeaderField(t.config.Hostname, 255),
+		syslogHeaderField(r.Entry.ServiceName, 48),
+		syslogHeaderField(t.procID, 128),
+		syslogHeaderField(r.Entry.Action, 32),
+		t.structuredData(r),
+		strings.TrimRight(r.Entry.Description, "\n"),
+	)
+}
+
+// structuredData renders the ErrorCode, the Attachments as JSON, and configured
+// static params as STRUCTURED-DATA, or NILVALUE if there are no params.
+func (t *SyslogTransport) structuredData(r *Record) string {
+	params := map[string]string{}
+	for k, v := range t.config.StructuredData {
+		params[k] = v
+	}
+	if r.Entry.ErrorCode != 0 {
+		params["errorCode"] = strconv.Itoa(r.Entry.ErrorCode)
+	}
+	if len(r.Attachments) > 0 {
+		params["attachments"] = attachmentsJSON(r.Attachments)
+	}
+	if len(params) == 0 {
+		return syslogNil
+	}
+
+	names := make([]string, 0, len(params))
+	for k := range params {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	sd := "[" + syslogSDName(t.config.SDID)
+	for _, k := range names {
+		sd += fmt.Sprintf(` %s="%s"`, syslogSDName(k), syslogSDValue(params[k]))
+	}
+	return sd + "]"
+}
+
+// syslogHeaderField restricts a header-field to printable US-ASCII of maxLen,
+// or NILVALUE if empty.
+func syslogHeaderField(s string, maxLen int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > maxLen {
+		s = s[:maxLen]
+	}
+	if s == "" {
+		return syslogNil
+	}
+	return s
+}
+
+// syslogSDName restricts an SD-NAME to its allowed characters and length.
+func syslogSDName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > 32 {
+		s = s[:32]
+	}
+	return s
+}
+
+// syslogSDValue escapes the characters not allowed in PARAM-VALUE.
+func syslogSDValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
+
+// Close closes the connection to syslog collector.
+func (t *SyslogTransport) Close() error {
+	return t.conn.close()
+}
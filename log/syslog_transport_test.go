@@ -0,0 +1,258 @@
+package log
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TerrexTech/go-common-models/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// newTestCert generates a self-signed certificate for localhost.
+func newTestCert() (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).ToNot(HaveOccurred())
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}, pool
+}
+
+// readOctetCountedFrames reads RFC 6587 octet-counted frames from conn into msgChan.
+func readOctetCountedFrames(conn net.Conn, msgChan chan<- string) {
+	defer GinkgoRecover()
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	for {
+		lenStr, err := reader.ReadString(' ')
+		if err != nil {
+			return
+		}
+		msgLen, err := strconv.Atoi(strings.TrimSpace(lenStr))
+		Expect(err).ToNot(HaveOccurred())
+
+		msg := make([]byte, msgLen)
+		_, err = io.ReadFull(reader, msg)
+		if err != nil {
+			return
+		}
+		msgChan <- string(msg)
+	}
+}
+
+var _ = Describe("SyslogTransport", func() {
+	var record *Record
+
+	BeforeEach(func() {
+		record = &Record{
+			Entry: model.LogEntry{
+				Action:      "test-action",
+				Description: "test-description\n",
+				ErrorCode:   4,
+				Level:       "ERROR",
+				ServiceName: "testsvc",
+			},
+			Time: time.Date(2018, 11, 9, 20, 35, 50, 0, time.UTC),
+		}
+	})
+
+	It("should format entries as RFC 5424 messages", func() {
+		t, err := NewSyslogTransport(SyslogConfig{
+			Network:  "udp",
+			Address:  "127.0.0.1:514",
+			Facility: FacilityLocal0,
+			Hostname: "test host",
+			StructuredData: map[string]string{
+				"env": `pr"od]`,
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		msg := t.format(record)
+		Expect(msg).To(Equal(
+			"<131>1 2018-11-09T20:35:50Z test_host testsvc " + t.procID +
+				` test-action [logtransport@32473 env="pr\"od\]" errorCode="4"]` +
+				" test-description",
+		))
+	})
+
+	It("should use NILVALUE for empty fields", func() {
+		t, err := NewSyslogTransport(SyslogConfig{
+			Network:  "udp",
+			Address:  "127.0.0.1:514",
+			Hostname: "host",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		msg := t.format(&Record{
+			Entry: model.LogEntry{
+				Description: "test",
+				Level:       "DEBUG",
+			},
+		})
+		Expect(msg).To(Equal("<15>1 - host - " + t.procID + " - - test"))
+	})
+
+	It("should add the attachments at DEBUG level to structured-data", func() {
+		t, err := NewSyslogTransport(SyslogConfig{
+			Network:  "udp",
+			Address:  "127.0.0.1:514",
+			Hostname: "host",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		msg := t.format(&Record{
+			Entry: model.LogEntry{
+				Description: "test",
+				Level:       "DEBUG",
+			},
+			Caller: "test.go:12",
+			Attachments: []Attachment{
+				{Type: "string", Value: json.RawMessage(`"a]b"`)},
+			},
+		})
+		Expect(msg).To(Equal(
+			"<15>1 - host - " + t.procID + " - " +
+				`[logtransport@32473 attachments="[{\"index\":0,\"type\":\"string\",` +
+				`\"value\":\"a\]b\"}\]"] test`,
+		))
+	})
+
+	It("should send messages over UDP", func() {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		t, err := NewSyslogTransport(SyslogConfig{
+			Network: "udp",
+			Address: conn.LocalAddr().String(),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer t.Close()
+		Expect(t.Write(record)).To(Succeed())
+
+		buf := make([]byte, 2048)
+		err = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		Expect(err).ToNot(HaveOccurred())
+		n, _, err := conn.ReadFrom(buf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(buf[:n])).To(HavePrefix("<11>1 "))
+		Expect(string(buf[:n])).To(HaveSuffix(" test-description"))
+	})
+
+	It("should send octet-counted messages over TCP and reconnect", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer listener.Close()
+
+		msgChan := make(chan string, 10)
+		connChan := make(chan net.Conn, 10)
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				connChan <- conn
+				go readOctetCountedFrames(conn, msgChan)
+			}
+		}()
+
+		t, err := NewSyslogTransport(SyslogConfig{
+			Network: "tcp",
+			Address: listener.Addr().String(),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer t.Close()
+
+		Expect(t.Write(record)).To(Succeed())
+		Eventually(msgChan).Should(Receive(HaveSuffix(" test-description")))
+
+		// Drop the connection, the transport should reconnect
+		(<-connChan).Close()
+		Eventually(func() int {
+			t.Write(record)
+			return len(connChan)
+		}, 5*time.Second, 50*time.Millisecond).Should(Equal(1))
+		Eventually(msgChan).Should(Receive(HaveSuffix(" test-description")))
+	})
+
+	It("should send octet-counted messages over TLS", func() {
+		cert, pool := newTestCert()
+		listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+			Certificates: []tls.Certificate{cert},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer listener.Close()
+
+		msgChan := make(chan string, 10)
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			readOctetCountedFrames(conn, msgChan)
+		}()
+
+		t, err := NewSyslogTransport(SyslogConfig{
+			Network: "tls",
+			Address: listener.Addr().String(),
+			TLSConfig: &tls.Config{
+				RootCAs:    pool,
+				ServerName: "localhost",
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer t.Close()
+
+		Expect(t.Write(record)).To(Succeed())
+		Eventually(msgChan).Should(Receive(ContainSubstring(`errorCode="4"`)))
+	})
+
+	It("should return error on invalid config", func() {
+		_, err := NewSyslogTransport(SyslogConfig{
+			Network: "http",
+			Address: "127.0.0.1:514",
+		})
+		Expect(err).To(HaveOccurred())
+
+		_, err = NewSyslogTransport(SyslogConfig{
+			Network: "tcp",
+		})
+		Expect(err).To(HaveOccurred())
+	})
+})
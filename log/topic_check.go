@@ -0,0 +1,91 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/TerrexTech/go-kafkautils/kafka"
+	"github.com/pkg/errors"
+)
+
+// TopicCheck configures the startup check of the Kafka topic, see WithTopicCheck.
+type TopicCheck struct {
+	// Create creates the topic if it does not exist.
+	// Otherwise, creating the Logger fails if the topic does not exist.
+	Create bool
+	// NumPartitions is the number of partitions of created topic. Default is 1.
+	NumPartitions int32
+	// ReplicationFactor is the replication-factor of created topic. Default is 1.
+	ReplicationFactor int16
+	// Retention is the retention-time of created topic.
+	// Default is broker's retention-time.
+	Retention time.Duration
+}
+
+// checkTopic verifies that the topic exists using Kafka's admin API,
+// and creates it if enabled.
+func checkTopic(config *kafka.ProducerConfig, topic string, check TopicCheck) error {
+	admin, err := sarama.NewClusterAdmin(
+		config.KafkaBrokers, copySaramaConfig(config.SaramaConfig),
+	)
+	if err != nil {
+		err = errors.Wrap(err, "Error creating Kafka cluster-admin for checking topic")
+		return err
+	}
+	defer admin.Close()
+
+	metadata, err := admin.DescribeTopics([]string{topic})
+	if err != nil {
+		err = errors.Wrapf(err, "Error describing Kafka topic %s", topic)
+		return err
+	}
+	for _, m := range metadata {
+		if m.Name != topic || m.Err == sarama.ErrUnknownTopicOrPartition {
+			continue
+		}
+		if m.Err != sarama.ErrNoError {
+			err = errors.Wrapf(m.Err, "Error describing Kafka topic %s", topic)
+			return err
+		}
+		return nil
+	}
+
+	if !check.Create {
+		return &ConfigError{
+			"Topic",
+			fmt.Sprintf("Kafka topic %s does not exist, and TopicCheck.Create is not set", topic),
+		}
+	}
+
+	detail := &sarama.TopicDetail{
+		NumPartitions:     check.NumPartitions,
+		ReplicationFactor: check.ReplicationFactor,
+	}
+	if detail.NumPartitions == 0 {
+		detail.NumPartitions = 1
+	}
+	if detail.ReplicationFactor == 0 {
+		detail.ReplicationFactor = 1
+	}
+	if check.Retention > 0 {
+		retention := strconv.FormatInt(int64(check.Retention/time.Millisecond), 10)
+		detail.ConfigEntries = map[string]*string{
+			"retention.ms": &retention,
+		}
+	}
+
+	err = admin.CreateTopic(topic, detail, false)
+	if topicErr, ok := err.(*sarama.TopicError); ok {
+		// Topic might be created concurrently, such as by another instance
+		if topicErr.Err == sarama.ErrTopicAlreadyExists {
+			return nil
+		}
+	}
+	if err != nil {
+		err = errors.Wrapf(err, "Error creating Kafka topic %s", topic)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package log
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/TerrexTech/go-kafkautils/kafka"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TopicCheck", func() {
+	var broker *sarama.MockBroker
+
+	BeforeEach(func() {
+		broker = sarama.NewMockBroker(GinkgoT(), 1)
+	})
+
+	AfterEach(func() {
+		broker.Close()
+	})
+
+	// setTopics sets the topics which exist on the mock broker.
+	setTopics := func(topics ...string) {
+		metadata := sarama.NewMockMetadataResponse(GinkgoT()).
+			SetController(broker.BrokerID()).
+			SetBroker(broker.Addr(), broker.BrokerID())
+		for _, topic := range topics {
+			metadata.SetLeader(topic, 0, broker.BrokerID())
+		}
+		broker.SetHandlerByMap(map[string]sarama.MockResponse{
+			"MetadataRequest":     metadata,
+			"CreateTopicsRequest": sarama.NewMockCreateTopicsResponse(GinkgoT()),
+		})
+	}
+
+	producerConfig := func() *kafka.ProducerConfig {
+		return &kafka.ProducerConfig{
+			KafkaBrokers: []string{broker.Addr()},
+			SaramaConfig: newSaramaConfig(),
+		}
+	}
+
+	// createRequests returns the CreateTopicsRequests received by mock broker.
+	createRequests := func() []*sarama.CreateTopicsRequest {
+		requests := []*sarama.CreateTopicsRequest{}
+		for _, rr := range broker.History() {
+			if req, ok := rr.Request.(*sarama.CreateTopicsRequest); ok {
+				requests = append(requests, req)
+			}
+		}
+		return requests
+	}
+
+	It("should succeed if topic exists", func() {
+		setTopics("test-topic")
+		err := checkTopic(producerConfig(), "test-topic", TopicCheck{Create: true})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(createRequests()).To(BeEmpty())
+	})
+
+	It("should return ConfigError if topic does not exist", func() {
+		setTopics("other-topic")
+		err := checkTopic(producerConfig(), "test-topic", TopicCheck{})
+		Expect(err).To(BeAssignableToTypeOf(&ConfigError{}))
+		Expect(err.(*ConfigError).Field).To(Equal("Topic"))
+		Expect(err.Error()).To(ContainSubstring("test-topic does not exist"))
+		Expect(createRequests()).To(BeEmpty())
+	})
+
+	It("should create topic with configured settings", func() {
+		setTopics()
+		err := checkTopic(producerConfig(), "test-topic", TopicCheck{
+			Create:            true,
+			NumPartitions:     3,
+			ReplicationFactor: 2,
+			Retention:         24 * time.Hour,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		requests := createRequests()
+		Expect(requests).To(HaveLen(1))
+		detail := requests[0].TopicDetails["test-topic"]
+		Expect(detail).ToNot(BeNil())
+		Expect(detail.NumPartitions).To(Equal(int32(3)))
+		Expect(detail.ReplicationFactor).To(Equal(int16(2)))
+		Expect(*detail.ConfigEntries["retention.ms"]).To(Equal("86400000"))
+	})
+
+	It("should fail Init if topic does not exist", func() {
+		setTopics()
+		_, err := Init(
+			context.Background(), "testsvc", producerConfig(), "test-topic",
+			WithTopicCheck(TopicCheck{}),
+		)
+		Expect(err).To(BeAssignableToTypeOf(&ConfigError{}))
+		Expect(err.(*ConfigError).Field).To(Equal("Topic"))
+	})
+
+	It("should return ConfigError for invalid settings", func() {
+		cases := [][]Option{
+			{WithTopicCheck(TopicCheck{NumPartitions: -1})},
+			{WithTopicCheck(TopicCheck{Retention: -time.Second})},
+			{
+				WithProducer(mocks.NewAsyncProducer(&mockReporter{}, nil)),
+				WithTopic("test-topic"),
+				WithTopicCheck(TopicCheck{}),
+			},
+		}
+		for _, opts := range cases {
+			_, err := InitWithOptions(context.Background(), "testsvc", opts...)
+			Expect(err).To(BeAssignableToTypeOf(&ConfigError{}))
+			Expect(err.(*ConfigError).Field).To(Equal("TopicCheck"))
+		}
+	})
+})
@@ -0,0 +1,334 @@
+package log
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/TerrexTech/go-common-models/model"
+	"github.com/pkg/errors"
+)
+
+// DefaultBufferSize is the number of log-entries queued for a Transport
+// before new entries for that Transport are dropped.
+const DefaultBufferSize = 256
+
+// Transport delivers log-entries to a destination, such as Kafka or a file.
+// Every Transport added to Logger is fed from its own goroutine, so
+// Write is never called concurrently for the same Transport.
+type Transport interface {
+	// Write delivers the Record to destination.
+	Write(r *Record) error
+	// Close flushes any pending Records and releases the resources.
+	Close() error
+}
+
+// Record is a log-entry as dispatched to Transports.
+type Record struct {
+	Entry model.LogEntry
+	// Key is the message-key as determined by Logger's KeyStrategy.
+	Key string
+	// Time is when the entry was logged.
+	Time time.Time
+	// Caller is the "file:line" from where the entry was logged.
+	// This and Attachments are only set when the Logger's level is DEBUG.
+	Caller string
+	// Attachments are the data arguments provided to the log-level function.
+	Attachments []Attachment
+}
+
+// RecordBuilder creates Records as the Logger does, redacting the entry and
+// attaching the data as per FormatLimits and RedactionRules. This allows
+// other Logger implementations, such as in logtest package, to write the
+// same Records to Transports.
+type RecordBuilder struct {
+	limits   FormatLimits
+	redactor *redactor
+}
+
+// NewRecordBuilder validates the limits and rules, and creates a RecordBuilder.
+func NewRecordBuilder(
+	limits FormatLimits,
+	rules ...RedactionRule,
+) (*RecordBuilder, error) {
+	err := limits.validate()
+	if err != nil {
+		err = errors.Wrap(err, "Error validating FormatLimits")
+		return nil, err
+	}
+	r, err := newRedactor(rules)
+	if err != nil {
+		err = errors.Wrap(err, "Error validating redaction-rules")
+		return nil, err
+	}
+	return &RecordBuilder{
+		limits:   limits,
+		redactor: r,
+	}, nil
+}
+
+// Build creates the Record of entry, whose Key is determined by strategy.
+// If debug is set, as when the Logger's level is DEBUG, the data is attached,
+// and Caller is set to the function skip frames above the caller of Build,
+// as in runtime.Caller.
+func (b *RecordBuilder) Build(
+	entry model.LogEntry,
+	strategy KeyStrategy,
+	debug bool,
+	skip int,
+	data ...interface{},
+) *Record {
+	return b.build(entry, strategy, debug, skip+1, data)
+}
+
+// build is same as Build, with skip relative to the caller of build.
+func (b *RecordBuilder) build(
+	entry model.LogEntry,
+	strategy KeyStrategy,
+	debug bool,
+	skip int,
+	data []interface{},
+) *Record {
+	if strategy == nil {
+		strategy = NoKey
+	}
+	redacted := false
+	b.redactor.redactEntry(&entry, &redacted)
+	entry.Description += "\n"
+	record := &Record{
+		Entry: entry,
+		Key:   strategy(entry, data...),
+		Time:  time.Now(),
+	}
+	if debug {
+		record.Caller = "???:-1"
+		if _, file, line, ok := runtime.Caller(skip + 1); ok {
+			record.Caller = fmt.Sprintf("%s:%d", file, line)
+		}
+		record.Attachments = newAttachments(b.limits, b.redactor, data...)
+	}
+	return record
+}
+
+// TransportConfig configures how log-entries are dispatched to a Transport.
+type TransportConfig struct {
+	// Name identifies the Transport in error-logs.
+	Name string
+	// Level is the minimum level of entries sent to Transport.
+	// Valid levels are: DEBUG, INFO and ERROR. Default is DEBUG, which sends
+	// all entries allowed by the LOG_LEVEL environment variable.
+	Level string
+	// BufferSize is the number of entries queued for Transport before new
+	// entries are dropped. Default is DefaultBufferSize.
+	BufferSize int
+}
+
+// levelRank orders the log-levels by severity.
+var levelRank = map[string]int{
+	"DEBUG": 0,
+	"INFO":  1,
+	"ERROR": 2,
+}
+
+// isLevelEnabled checks if an entry of provided level passes the minimum level.
+func isLevelEnabled(minLevel string, level string) bool {
+	if minLevel == "" {
+		return true
+	}
+	return levelRank[level] >= levelRank[minLevel]
+}
+
+// dispatcher feeds a single Transport from its own queue, so a stalled
+// Transport does not block the others.
+type dispatcher struct {
+	config    TransportConfig
+	transport Transport
+	// match, if not nil, selects the Records sent to Transport
+	match    func(r *Record) bool
+	recChan  chan *Record
+	done     chan struct{}
+	dropped  int
+	dropLock sync.Mutex
+}
+
+func newDispatcher(t Transport, config TransportConfig) (*dispatcher, error) {
+	if t == nil {
+		return nil, errors.New("nil transport provided")
+	}
+	if config.Level != "" {
+		if _, ok := levelRank[config.Level]; !ok {
+			return nil, errors.Errorf(
+				"invalid level %s for transport %s", config.Level, config.Name,
+			)
+		}
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = DefaultBufferSize
+	}
+
+	d := &dispatcher{
+		config:    config,
+		transport: t,
+		recChan:   make(chan *Record, config.BufferSize),
+		done:      make(chan struct{}),
+	}
+	go d.run()
+	return d, nil
+}
+
+// dispatch queues the Record without blocking.
+// The Record is dropped if the queue is full.
+func (d *dispatcher) dispatch(r *Record) {
+	if !isLevelEnabled(d.config.Level, r.Entry.Level) {
+		return
+	}
+	if d.match != nil && !d.match(r) {
+		return
+	}
+	select {
+	case d.recChan <- r:
+	default:
+		d.dropLock.Lock()
+		d.dropped++
+		d.dropLock.Unlock()
+	}
+}
+
+func (d *dispatcher) run() {
+	defer close(d.done)
+
+	for r := range d.recChan {
+		err := d.transport.Write(r)
+		if err != nil {
+			err = errors.Wrapf(err, "Error in LogTransport %s", d.config.Name)
+			log.Println(err)
+		}
+
+		d.dropLock.Lock()
+		dropped := d.dropped
+		d.dropped = 0
+		d.dropLock.Unlock()
+		if dropped > 0 {
+			log.Printf(
+				"LogTransport %s: dropped %d entries because the queue was full",
+				d.config.Name, dropped,
+			)
+		}
+	}
+}
+
+// close drains the queue and closes the Transport.
+func (d *dispatcher) close() error {
+	close(d.recChan)
+	<-d.done
+	return d.transport.Close()
+}
+
+// fanout dispatches Records to all added Transports.
+type fanout struct {
+	lock        sync.RWMutex
+	closed      bool
+	dispatchers []*dispatcher
+}
+
+func (f *fanout) add(t Transport, config TransportConfig) error {
+	d, err := newDispatcher(t, config)
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	if f.closed {
+		f.lock.Unlock()
+		closeDispatchers([]*dispatcher{d})
+		return errors.New("logger is closed")
+	}
+	f.dispatchers = append(f.dispatchers, d)
+	f.lock.Unlock()
+	return nil
+}
+
+func (f *fanout) dispatch(r *Record) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if f.closed {
+		return
+	}
+	for _, d := range f.dispatchers {
+		d.dispatch(r)
+	}
+}
+
+// swap replaces the removed dispatchers with the added ones, so no Record is
+// sent to both. The removed dispatchers are closed after their queued Records
+// are written.
+func (f *fanout) swap(removed []*dispatcher, added []*dispatcher) error {
+	f.lock.Lock()
+	if f.closed {
+		f.lock.Unlock()
+		closeDispatchers(added)
+		return errors.New("logger is closed")
+	}
+
+	dispatchers := []*dispatcher{}
+	for _, d := range f.dispatchers {
+		keep := true
+		for _, r := range removed {
+			if d == r {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			dispatchers = append(dispatchers, d)
+		}
+	}
+	f.dispatchers = append(dispatchers, added...)
+	f.lock.Unlock()
+
+	closeDispatchers(removed)
+	return nil
+}
+
+// close closes all Transports, after their queued Records are written.
+func (f *fanout) close() {
+	f.lock.Lock()
+	if f.closed {
+		f.lock.Unlock()
+		return
+	}
+	f.closed = true
+	f.lock.Unlock()
+
+	closeDispatchers(f.dispatchers)
+}
+
+// closeWithin closes the fanout like close, but waits at most timeout.
+func (f *fanout) closeWithin(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.close()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		log.Println("LogTransport: timed out closing Transports")
+	}
+}
+
+// closeDispatchers closes the dispatchers, logging any errors.
+func closeDispatchers(dispatchers []*dispatcher) {
+	for _, d := range dispatchers {
+		err := d.close()
+		if err != nil {
+			err = errors.Wrapf(err, "Error closing LogTransport %s", d.config.Name)
+			log.Println(err)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"sync"
+
+	"github.com/TerrexTech/go-common-models/model"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// mockTransport records the Records written to it.
+type mockTransport struct {
+	lock    sync.Mutex
+	records []*Record
+	closed  bool
+	// block, if not nil, blocks Write until closed
+	block chan struct{}
+}
+
+func (t *mockTransport) Write(r *Record) error {
+	if t.block != nil {
+		<-t.block
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.records = append(t.records, r)
+	return nil
+}
+
+func (t *mockTransport) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.closed = true
+	return nil
+}
+
+func (t *mockTransport) levels() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	levels := []string{}
+	for _, r := range t.records {
+		levels = append(levels, r.Entry.Level)
+	}
+	return levels
+}
+
+var _ = Describe("Transport", func() {
+	var l *logger
+
+	BeforeEach(func() {
+		err := os.Setenv(LogLevelEnvVar, "DEBUG")
+		Expect(err).ToNot(HaveOccurred())
+
+		l = &logger{
+			limits:       DefaultFormatLimits,
+			transports:   &fanout{},
+			enableOutput: false,
+			output:       &bytes.Buffer{},
+			svcName:      "testsvc",
+			keyStrategy:  NoKey,
+		}
+	})
+
+	It("should send entries to every transport as per its level", func() {
+		debugT := &mockTransport{}
+		errorT := &mockTransport{}
+		Expect(l.AddTransport(debugT, TransportConfig{Name: "debug"})).To(Succeed())
+		Expect(l.AddTransport(errorT, TransportConfig{
+			Name:  "error",
+			Level: "ERROR",
+		})).To(Succeed())
+
+		l.D(Entry{Description: "d"})
+		l.I(Entry{Description: "i"})
+		l.E(Entry{Description: "e"})
+		l.transports.close()
+
+		Expect(debugT.levels()).To(Equal([]string{"DEBUG", "INFO", "ERROR"}))
+		Expect(errorT.levels()).To(Equal([]string{"ERROR"}))
+		Expect(debugT.closed).To(BeTrue())
+		Expect(errorT.closed).To(BeTrue())
+	})
+
+	It("should not block other transports when a transport is stalled", func() {
+		stalledT := &mockTransport{block: make(chan struct{})}
+		fastT := &mockTransport{}
+		Expect(l.AddTransport(stalledT, TransportConfig{
+			Name:       "stalled",
+			BufferSize: 1,
+		})).To(Succeed())
+		Expect(l.AddTransport(fastT, TransportConfig{Name: "fast"})).To(Succeed())
+
+		for i := 0; i < 10; i++ {
+			l.I(Entry{Description: "test"})
+		}
+		Eventually(func() int {
+			return len(fastT.levels())
+		}).Should(Equal(10))
+
+		close(stalledT.block)
+		l.transports.close()
+		Expect(len(stalledT.levels())).To(BeNumerically("<", 10))
+	})
+
+	It("should send fatal entries to transports before exiting", func() {
+		exitCode := -1
+		exit = func(code int) {
+			exitCode = code
+		}
+		defer func() {
+			exit = os.Exit
+		}()
+
+		mock := &mockTransport{}
+		Expect(l.AddTransport(mock, TransportConfig{Name: "mock"})).To(Succeed())
+		l.F(Entry{Description: "fatal"})
+
+		Expect(exitCode).To(Equal(1))
+		Expect(mock.levels()).To(Equal([]string{"ERROR"}))
+		Expect(mock.closed).To(BeTrue())
+	})
+
+	It("should return error on invalid transport level", func() {
+		err := l.AddTransport(&mockTransport{}, TransportConfig{Level: "WARN"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should not accept transports after logger is closed", func() {
+		l.transports.close()
+		mock := &mockTransport{}
+		err := l.AddTransport(mock, TransportConfig{})
+		Expect(err).To(HaveOccurred())
+		Expect(mock.closed).To(BeTrue())
+	})
+
+	It("should write formatted entries using WriterTransport", func() {
+		buf := &bytes.Buffer{}
+		wt, err := NewWriterTransport(buf, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(l.AddTransport(wt, TransportConfig{})).To(Succeed())
+
+		err = os.Setenv(LogLevelEnvVar, "INFO")
+		Expect(err).ToNot(HaveOccurred())
+		l.I(Entry{Description: "test-description"})
+		l.transports.close()
+		Expect(buf.String()).To(ContainSubstring(`"description":"test-description\n"`))
+		Expect(buf.String()).To(HaveSuffix("}\n"))
+	})
+
+	It("should set Caller of DEBUG entries to where they were logged", func() {
+		mock := &mockTransport{}
+		Expect(l.AddTransport(mock, TransportConfig{Name: "mock"})).To(Succeed())
+		l.D(Entry{Description: "d"}, "data")
+		l.transports.close()
+
+		Expect(mock.records).To(HaveLen(1))
+		Expect(mock.records[0].Caller).To(ContainSubstring("transport_test.go:"))
+		Expect(mock.records[0].Attachments).To(HaveLen(1))
+	})
+
+	It("should build Records as Logger does using RecordBuilder", func() {
+		b, err := NewRecordBuilder(
+			DefaultFormatLimits, RedactionRule{Key: "password"}, RedactionRule{Value: "secret"},
+		)
+		Expect(err).ToNot(HaveOccurred())
+
+		r := b.Build(
+			model.LogEntry{Description: "a secret", Level: "INFO"},
+			nil, true, 0,
+			map[string]string{"password": "pass"},
+		)
+		Expect(r.Entry.Description).To(Equal("a [REDACTED]\n"))
+		Expect(r.Caller).To(ContainSubstring("transport_test.go:"))
+		Expect(r.Attachments).To(HaveLen(1))
+		Expect(r.Attachments[0].Redacted).To(BeTrue())
+
+		r = b.Build(model.LogEntry{Description: "info"}, nil, false, 0, "data")
+		Expect(r.Caller).To(BeEmpty())
+		Expect(r.Attachments).To(BeNil())
+
+		_, err = NewRecordBuilder(DefaultFormatLimits, RedactionRule{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return error from WriterTransport if formatter fails", func() {
+		wt, err := NewWriterTransport(&bytes.Buffer{}, FormatterFunc(
+			func(*Record) ([]byte, error) {
+				return nil, errors.New("some error")
+			},
+		))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(wt.Write(&Record{})).ToNot(Succeed())
+	})
+})
@@ -0,0 +1,207 @@
+package log
+
+import (
+	"reflect"
+	"sync"
+)
+
+// LogValuer is implemented by types which provide their own value for
+// attachments, such as to parse nested JSON or to omit large fields.
+// The returned value is formatted recursively.
+type LogValuer interface {
+	LogValue() interface{}
+}
+
+var logValuerType = reflect.TypeOf((*LogValuer)(nil)).Elem()
+
+// maxFormatDepth limits the nesting of formatted values,
+// such as when a formatter returns a value of its own type.
+const maxFormatDepth = 32
+
+// typeFormatters are the formatters registered using RegisterFormatter.
+var typeFormatters = struct {
+	sync.RWMutex
+	funcs map[reflect.Type]func(v interface{}) interface{}
+	// needs caches the result of needsFormatting,
+	// and is reset when a formatter is registered.
+	needs map[reflect.Type]bool
+}{
+	funcs: map[reflect.Type]func(v interface{}) interface{}{},
+	needs: map[reflect.Type]bool{},
+}
+
+// RegisterFormatter registers f for formatting the values of type t, such as
+// domain-types, when attaching data at DEBUG level. The value returned by f is
+// marshalled as JSON instead of the original value, and is formatted
+// recursively, so f can return values of other registered types.
+// Formatters are also applied to values nested in structs, maps and slices.
+// Pointers are dereferenced before calling f, so t should not be a pointer-type.
+// A registered formatter takes precedence over the type's LogValue method.
+// The types of DefaultModelSchemas are registered by default, and registering
+// nil removes the formatter for t.
+func RegisterFormatter(t reflect.Type, f func(v interface{}) interface{}) {
+	if t == nil {
+		return
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	typeFormatters.Lock()
+	defer typeFormatters.Unlock()
+	if f == nil {
+		delete(typeFormatters.funcs, t)
+	} else {
+		typeFormatters.funcs[t] = f
+	}
+	typeFormatters.needs = map[reflect.Type]bool{}
+}
+
+func typeFormatter(t reflect.Type) func(v interface{}) interface{} {
+	typeFormatters.RLock()
+	defer typeFormatters.RUnlock()
+	return typeFormatters.funcs[t]
+}
+
+// formatValue applies the registered formatters and LogValue methods
+// to d, and the values nested in it. redacted is set if the fields tagged
+// as redact in the values returned by formatters are masked.
+func formatValue(d interface{}, depth int, redacted *bool) interface{} {
+	if lv, ok := d.(LogValuer); ok && depth < maxFormatDepth {
+		if v := reflect.ValueOf(d); v.Kind() != reflect.Ptr || !v.IsNil() {
+			if typeFormatter(reflect.Indirect(v).Type()) == nil {
+				return formatReturned(lv.LogValue(), depth+1, redacted)
+			}
+		}
+	}
+	d = derefData(d)
+	if d == nil || depth >= maxFormatDepth {
+		return d
+	}
+
+	v := reflect.ValueOf(d)
+	if f := typeFormatter(v.Type()); f != nil {
+		return formatReturned(f(d), depth+1, redacted)
+	}
+	// LogValue might have a pointer-receiver
+	if reflect.PtrTo(v.Type()).Implements(logValuerType) {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		return formatReturned(
+			p.Interface().(LogValuer).LogValue(), depth+1, redacted,
+		)
+	}
+	if !needsFormatting(v.Type()) {
+		return d
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		values := make([]interface{}, v.Len())
+		for i := range values {
+			values[i] = formatValue(v.Index(i).Interface(), depth+1, redacted)
+		}
+		return values
+
+	case reflect.Map:
+		values := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			key, ok := mapKeyString(k)
+			if !ok {
+				return d
+			}
+			values[key] = formatValue(v.MapIndex(k).Interface(), depth+1, redacted)
+		}
+		return values
+
+	case reflect.Struct:
+		// The struct is marshalled as is, and only the fields needing
+		// formatting are replaced, so its JSON-tags are still respected.
+		m := map[string]interface{}{}
+		if decodeJSON(d, &m) != nil {
+			return d
+		}
+		walkJSONFields(v, func(name string, f reflect.StructField, fv reflect.Value) {
+			if _, ok := m[name]; ok && fv.CanInterface() && needsFormatting(f.Type) {
+				m[name] = formatValue(fv.Interface(), depth+1, redacted)
+			}
+		})
+		return m
+	}
+	return d
+}
+
+// formatReturned formats the value returned by a formatter or LogValue method,
+// and masks its fields tagged as redact, since only the tags of the original
+// value are known when the attachment is redacted.
+func formatReturned(r interface{}, depth int, redacted *bool) interface{} {
+	value := formatValue(r, depth, redacted)
+	v := reflect.ValueOf(r)
+	if !v.IsValid() || !hasRedactTags(v.Type()) {
+		return value
+	}
+
+	var generic interface{}
+	if decodeJSON(value, &generic) != nil {
+		return value
+	}
+	return redactTagged(v, generic, redacted)
+}
+
+// needsFormatting checks if values of type t can contain values of types
+// with registered formatters or LogValue methods.
+func needsFormatting(t reflect.Type) bool {
+	typeFormatters.RLock()
+	needs, ok := typeFormatters.needs[t]
+	if !ok {
+		// Only the result for t is cached, since results for nested types
+		// are incomplete for recursive types.
+		needs = typeNeedsFormatting(t, map[reflect.Type]bool{})
+	}
+	typeFormatters.RUnlock()
+
+	if !ok {
+		typeFormatters.Lock()
+		typeFormatters.needs[t] = needs
+		typeFormatters.Unlock()
+	}
+	return needs
+}
+
+// typeNeedsFormatting is same as needsFormatting,
+// and requires typeFormatters to be locked.
+func typeNeedsFormatting(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	if _, ok := typeFormatters.funcs[t]; ok {
+		return true
+	}
+	if t.Implements(logValuerType) || reflect.PtrTo(t).Implements(logValuerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		// Dynamic values are checked when formatting
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// Byte-slices are marshalled as base64-strings
+			return false
+		}
+		return typeNeedsFormatting(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue
+			}
+			if typeNeedsFormatting(f.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package log
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/TerrexTech/go-common-models/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// valuer formats itself using LogValue.
+type valuer struct {
+	Name string
+}
+
+func (v valuer) LogValue() interface{} {
+	return "valuer " + v.Name
+}
+
+// ptrValuer implements LogValuer with a pointer-receiver.
+type ptrValuer struct {
+	Name string
+}
+
+func (v *ptrValuer) LogValue() interface{} {
+	return map[string]string{"ptr": v.Name}
+}
+
+// customerList is formatted using a registered formatter in tests.
+type customerList struct{}
+
+// secretValuer returns a value with a field tagged as redact.
+type secretValuer struct {
+	Token string
+}
+
+func (v secretValuer) LogValue() interface{} {
+	return struct {
+		Token string `json:"token" log:"redact"`
+		Name  string `json:"name"`
+	}{v.Token, "secret"}
+}
+
+// selfValuer returns a value of its own type.
+type selfValuer struct {
+	N int
+}
+
+func (v selfValuer) LogValue() interface{} {
+	return selfValuer{N: v.N + 1}
+}
+
+var _ = Describe("TypeFormatter", func() {
+	type customer struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	type order struct {
+		ID       string      `json:"orderID"`
+		Customer customer    `json:"customer"`
+		Items    []valuer    `json:"items"`
+		Owner    *ptrValuer  `json:"owner"`
+		Extra    interface{} `json:"extra,omitempty"`
+		Skipped  customer    `json:"-"`
+	}
+
+	customerType := reflect.TypeOf(customer{})
+
+	BeforeEach(func() {
+		RegisterFormatter(customerType, func(v interface{}) interface{} {
+			c := v.(customer)
+			return map[string]interface{}{
+				"customer": c.Name,
+				"event":    model.Document{Data: []byte(`{"id":1}`)},
+			}
+		})
+	})
+
+	AfterEach(func() {
+		RegisterFormatter(customerType, nil)
+	})
+
+	// decode returns the decoded Value of attachment.
+	decode := func(a Attachment) interface{} {
+		Expect(a.Error).To(BeEmpty())
+		var value interface{}
+		Expect(json.Unmarshal(a.Value, &value)).To(Succeed())
+		return value
+	}
+
+	It("should apply formatters and LogValue methods recursively", func() {
+		a := newAttachment(0, &order{
+			ID:       "o1",
+			Customer: customer{ID: 1, Name: "test"},
+			Items:    []valuer{{Name: "a"}, {Name: "b"}},
+			Owner:    &ptrValuer{Name: "owner"},
+			Extra:    map[string]interface{}{"c": &customer{Name: "extra"}},
+		}, DefaultFormatLimits, nil)
+		Expect(a.Type).To(Equal("log.order"))
+
+		value := decode(a).(map[string]interface{})
+		Expect(value["orderID"]).To(Equal("o1"))
+		Expect(value["items"]).To(Equal([]interface{}{"valuer a", "valuer b"}))
+		Expect(value["owner"]).To(Equal(map[string]interface{}{"ptr": "owner"}))
+		Expect(value).ToNot(HaveKey("Skipped"))
+
+		c := value["customer"].(map[string]interface{})
+		Expect(c["customer"]).To(Equal("test"))
+		// Registered Common-Models are formatted too
+		event := c["event"].(map[string]interface{})
+		Expect(event["data"]).To(Equal(map[string]interface{}{"id": float64(1)}))
+
+		extra := value["extra"].(map[string]interface{})
+		Expect(extra["c"]).To(HaveKeyWithValue("customer", "extra"))
+	})
+
+	It("should apply LogValue methods with pointer-receivers to values", func() {
+		a := newAttachment(0, []ptrValuer{{Name: "a"}}, DefaultFormatLimits, nil)
+		Expect(decode(a)).To(Equal([]interface{}{
+			map[string]interface{}{"ptr": "a"},
+		}))
+		Expect(a.Length).To(Equal(1))
+	})
+
+	It("should prefer registered formatters over LogValue methods", func() {
+		valuerType := reflect.TypeOf(&valuer{})
+		RegisterFormatter(valuerType, func(v interface{}) interface{} {
+			return "registered " + v.(valuer).Name
+		})
+		defer RegisterFormatter(valuerType, nil)
+
+		a := newAttachment(0, valuer{Name: "a"}, DefaultFormatLimits, nil)
+		Expect(decode(a)).To(Equal("registered a"))
+	})
+
+	It("should mask the redact-tagged fields of returned values", func() {
+		type holder struct {
+			Secret secretValuer `json:"secret"`
+		}
+		a := newAttachment(0, holder{secretValuer{"supersecret"}}, DefaultFormatLimits, nil)
+		Expect(a.Redacted).To(BeTrue())
+		Expect(decode(a)).To(Equal(map[string]interface{}{
+			"secret": map[string]interface{}{"token": "[REDACTED]", "name": "secret"},
+		}))
+
+		customers := reflect.TypeOf(customerList{})
+		RegisterFormatter(customers, func(v interface{}) interface{} {
+			return []secretValuer{{"a"}}
+		})
+		defer RegisterFormatter(customers, nil)
+		a = newAttachment(0, customerList{}, DefaultFormatLimits, nil)
+		Expect(a.Redacted).To(BeTrue())
+		Expect(string(a.Value)).ToNot(ContainSubstring(`"a"`))
+	})
+
+	It("should stop formatting values returning their own type", func() {
+		a := newAttachment(0, selfValuer{}, DefaultFormatLimits, nil)
+		Expect(decode(a)).To(Equal(map[string]interface{}{
+			"N": float64(maxFormatDepth),
+		}))
+	})
+
+	It("should keep values without formatters as is", func() {
+		redacted := false
+		meta := model.EventMeta{AggregateID: 2}
+		Expect(formatValue(meta, 0, &redacted)).To(Equal(meta))
+		Expect(formatValue([]byte("test"), 0, &redacted)).To(Equal([]byte("test")))
+		Expect(redacted).To(BeFalse())
+		Expect(needsFormatting(reflect.TypeOf(meta))).To(BeFalse())
+		Expect(needsFormatting(reflect.TypeOf(order{}))).To(BeTrue())
+	})
+})
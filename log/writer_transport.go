@@ -0,0 +1,59 @@
+package log
+
+import (
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// WriterTransport writes formatted log-entries to an io.Writer, such as Stdout.
+type WriterTransport struct {
+	w         io.Writer
+	formatter Formatter
+}
+
+// NewWriterTransport creates a Transport writing to w.
+// JSONFormatter is used if formatter is nil.
+func NewWriterTransport(w io.Writer, formatter Formatter) (*WriterTransport, error) {
+	if w == nil {
+		return nil, errors.New("nil writer provided")
+	}
+	if formatter == nil {
+		formatter = JSONFormatter{}
+	}
+	return &WriterTransport{
+		w:         w,
+		formatter: formatter,
+	}, nil
+}
+
+// Write formats the Record and writes it to writer.
+func (t *WriterTransport) Write(r *Record) error {
+	b, err := t.formatter.Format(r)
+	if err != nil {
+		err = errors.Wrap(err, "Error formatting log-entry")
+		return err
+	}
+	_, err = t.w.Write(b)
+	if err != nil {
+		err = errors.Wrap(err, "Error writing log-entry")
+	}
+	return err
+}
+
+// Close closes the writer if it is an io.Closer, unless the writer is Stdout or Stderr.
+func (t *WriterTransport) Close() error {
+	if isStdStream(t.w) {
+		return nil
+	}
+	if c, ok := t.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// isStdStream checks if w is Stdout or Stderr.
+func isStdStream(w io.Writer) bool {
+	return w == os.Stdout || w == os.Stderr
+}
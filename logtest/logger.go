@@ -0,0 +1,417 @@
+// Package logtest provides an in-memory recording log.Logger, and Gomega
+// matchers for asserting the logged entries in tests, without Kafka.
+package logtest
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TerrexTech/go-common-models/model"
+	"github.com/TerrexTech/go-logtransport/log"
+	"github.com/pkg/errors"
+)
+
+// Log-levels of recorded entries.
+const (
+	Debug = "DEBUG"
+	Info  = "INFO"
+	Error = "ERROR"
+)
+
+// Logged is a recorded log-entry.
+type Logged struct {
+	Level string
+	Entry log.Entry
+	// Data is the additional data provided when logging the entry.
+	Data []interface{}
+	// Fatal is true if the entry was logged using F.
+	Fatal bool
+	Time  time.Time
+}
+
+func (e Logged) String() string {
+	return fmt.Sprintf(
+		"%s: %d: %s: %s: %s (%d data)",
+		e.Level, e.Entry.ErrorCode, e.Entry.ServiceName,
+		e.Entry.Action, strings.TrimSpace(e.Entry.Description), len(e.Data),
+	)
+}
+
+// Logger is a log.Logger which records the entries in memory.
+// All entries are recorded regardless of LOG_LEVEL environment variable,
+// and F does not exit the program. Default ServiceName and Action are
+// applied to entries as by log.Logger. Entries are recorded as provided, while
+// the Records written to output and to Transports added using AddTransport are
+// built as by a log.Logger at DEBUG level, with the data attached and redacted.
+// Transports are written to synchronously.
+type Logger struct {
+	svcName string
+
+	lock         sync.Mutex
+	action       string
+	entries      []Logged
+	changed      chan struct{}
+	output       io.Writer
+	formatter    log.Formatter
+	enableOutput bool
+	keyStrategy  log.KeyStrategy
+	transports   []log.Transport
+	limits       log.FormatLimits
+	rules        []log.RedactionRule
+	builder      *log.RecordBuilder
+}
+
+var _ log.Logger = &Logger{}
+
+// New creates a recording Logger using svcName as default ServiceName.
+func New(svcName string) *Logger {
+	// Default limits are valid, and there are no rules
+	builder, _ := log.NewRecordBuilder(log.DefaultFormatLimits)
+	return &Logger{
+		svcName:      svcName,
+		changed:      make(chan struct{}),
+		enableOutput: true,
+		keyStrategy:  log.NoKey,
+		limits:       log.DefaultFormatLimits,
+		builder:      builder,
+	}
+}
+
+func (l *Logger) record(level string, fatal bool, entry log.Entry, data []interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if entry.ServiceName == "" {
+		entry.ServiceName = l.svcName
+	}
+	if entry.Action == "" {
+		entry.Action = l.action
+	}
+	logged := Logged{
+		Level: level,
+		Entry: entry,
+		Data:  data,
+		Fatal: fatal,
+		Time:  time.Now(),
+	}
+	l.entries = append(l.entries, logged)
+
+	logEntry := model.LogEntry{
+		Action:      entry.Action,
+		Description: entry.Description,
+		ErrorCode:   entry.ErrorCode,
+		Level:       level,
+		ServiceName: entry.ServiceName,
+	}
+	// Skip this and the log-level function
+	r := l.builder.Build(logEntry, l.keyStrategy, true, 2, data...)
+	r.Time = logged.Time
+
+	if l.enableOutput && l.output != nil {
+		// Output the redacted entry
+		redacted := logged
+		redacted.Entry = log.Entry{
+			Action:      r.Entry.Action,
+			Description: r.Entry.Description,
+			ErrorCode:   r.Entry.ErrorCode,
+			ServiceName: r.Entry.ServiceName,
+		}
+		out := []byte(redacted.String() + "\n")
+		if l.formatter != nil {
+			formatted, err := l.formatter.Format(r)
+			if err == nil {
+				out = formatted
+			}
+		}
+		l.output.Write(out)
+	}
+	for _, t := range l.transports {
+		t.Write(r)
+	}
+
+	// Wake up the waiters
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// D records a DEBUG entry.
+func (l *Logger) D(entry log.Entry, data ...interface{}) {
+	l.record(Debug, false, entry, data)
+}
+
+// E records an ERROR entry.
+func (l *Logger) E(entry log.Entry, data ...interface{}) {
+	l.record(Error, false, entry, data)
+}
+
+// F records an ERROR entry marked as Fatal. The program is not exited.
+func (l *Logger) F(entry log.Entry, data ...interface{}) {
+	l.record(Error, true, entry, data)
+}
+
+// I records an INFO entry.
+func (l *Logger) I(entry log.Entry, data ...interface{}) {
+	l.record(Info, false, entry, data)
+}
+
+// DisableOutput disables writing the recorded entries to output.
+func (l *Logger) DisableOutput() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.enableOutput = false
+}
+
+// EnableOutput enables writing the recorded entries to output, if set.
+func (l *Logger) EnableOutput() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.enableOutput = true
+}
+
+// SetArrayThreshold sets threshold for the length of arrays attached to
+// Records. Thresholds which are not positive are ignored, as by log.Logger.
+func (l *Logger) SetArrayThreshold(threshold int) {
+	if threshold <= 0 {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	limits := l.limits
+	limits.ArrayThreshold = threshold
+	// Limits with positive threshold are valid, as are the current rules
+	builder, err := log.NewRecordBuilder(limits, l.rules...)
+	if err == nil {
+		l.limits = limits
+		l.builder = builder
+	}
+}
+
+// SetFormatLimits sets the limits applied to data attached to Records.
+// An error is returned for invalid limits, as by log.Logger.
+func (l *Logger) SetFormatLimits(limits log.FormatLimits) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	builder, err := log.NewRecordBuilder(limits, l.rules...)
+	if err != nil {
+		err = errors.Wrap(err, "Error setting FormatLimits")
+		return err
+	}
+	l.limits = limits
+	l.builder = builder
+	return nil
+}
+
+// SetRedaction sets the rules for masking sensitive data in Records.
+// The recorded entries are not masked, so tests can assert them.
+func (l *Logger) SetRedaction(rules ...log.RedactionRule) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	builder, err := log.NewRecordBuilder(l.limits, rules...)
+	if err != nil {
+		err = errors.Wrap(err, "Error setting redaction-rules")
+		return err
+	}
+	l.rules = rules
+	l.builder = builder
+	return nil
+}
+
+// AddTransport adds a Transport to which the entries are written synchronously.
+func (l *Logger) AddTransport(t log.Transport, config log.TransportConfig) error {
+	if t == nil {
+		return errors.New("nil transport provided")
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.transports = append(l.transports, t)
+	return nil
+}
+
+// SetKeyStrategy sets the strategy used to determine keys of Records
+// written to Transports.
+func (l *Logger) SetKeyStrategy(strategy log.KeyStrategy) {
+	if strategy == nil {
+		strategy = log.NoKey
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.keyStrategy = strategy
+}
+
+// SetAction sets default Action for entries which have none.
+func (l *Logger) SetAction(action string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.action = action
+}
+
+// SetOutput sets the output to which the recorded entries are written,
+// which helps debugging tests. Entries are not written by default.
+func (l *Logger) SetOutput(w io.Writer) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.output = w
+}
+
+// SetFormatter sets the Formatter used for writing the recorded entries
+// to output. By default, entries are written as by Logged.String.
+func (l *Logger) SetFormatter(f log.Formatter) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.formatter = f
+}
+
+// Entries returns all recorded entries in the order they were logged.
+func (l *Logger) Entries() []Logged {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	entries := make([]Logged, len(l.entries))
+	copy(entries, l.entries)
+	return entries
+}
+
+// Filter returns the recorded entries of level matching all predicates.
+// Entries of any level are matched if level is empty.
+func (l *Logger) Filter(level string, predicates ...Predicate) []Logged {
+	matched := []Logged{}
+	for _, e := range l.Entries() {
+		if matches(e, level, predicates) {
+			matched = append(matched, e)
+		}
+	}
+	return matched
+}
+
+// Count returns the number of recorded entries of level matching all predicates.
+func (l *Logger) Count(level string, predicates ...Predicate) int {
+	return len(l.Filter(level, predicates...))
+}
+
+// Reset removes all recorded entries.
+func (l *Logger) Reset() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.entries = nil
+}
+
+// WaitFor waits until an entry of level matching all predicates is logged,
+// such as by another goroutine, and returns the first such entry.
+// An error is returned if no such entry is logged within timeout.
+func (l *Logger) WaitFor(
+	timeout time.Duration, level string, predicates ...Predicate,
+) (Logged, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		l.lock.Lock()
+		for _, e := range l.entries {
+			if matches(e, level, predicates) {
+				l.lock.Unlock()
+				return e, nil
+			}
+		}
+		changed := l.changed
+		l.lock.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return Logged{}, errors.Errorf(
+				"no %s entry %s was logged within %s",
+				describeLevel(level), describe(predicates), timeout,
+			)
+		}
+	}
+}
+
+func matches(e Logged, level string, predicates []Predicate) bool {
+	if level != "" && e.Level != level {
+		return false
+	}
+	for _, p := range predicates {
+		if !p.match(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// Predicate matches recorded entries.
+type Predicate struct {
+	description string
+	match       func(e Logged) bool
+}
+
+// Where creates a Predicate from a function, described by description
+// in failure-messages.
+func Where(description string, match func(e Logged) bool) Predicate {
+	return Predicate{
+		description: description,
+		match:       match,
+	}
+}
+
+// WithAction matches entries with provided Action.
+func WithAction(action string) Predicate {
+	return Where(fmt.Sprintf("with Action %q", action), func(e Logged) bool {
+		return e.Entry.Action == action
+	})
+}
+
+// WithErrorCode matches entries with provided ErrorCode.
+func WithErrorCode(code int) Predicate {
+	return Where(fmt.Sprintf("with ErrorCode %d", code), func(e Logged) bool {
+		return e.Entry.ErrorCode == code
+	})
+}
+
+// WithServiceName matches entries with provided ServiceName.
+func WithServiceName(svcName string) Predicate {
+	return Where(fmt.Sprintf("with ServiceName %q", svcName), func(e Logged) bool {
+		return e.Entry.ServiceName == svcName
+	})
+}
+
+// WithDescription matches entries whose Description contains substr.
+func WithDescription(substr string) Predicate {
+	desc := fmt.Sprintf("with Description containing %q", substr)
+	return Where(desc, func(e Logged) bool {
+		return strings.Contains(e.Entry.Description, substr)
+	})
+}
+
+// WithData matches entries having data of same type as sample, or a pointer to it.
+// For example, WithData(model.Event{}) matches entries logged with
+// a model.Event or *model.Event.
+func WithData(sample interface{}) Predicate {
+	t := reflect.TypeOf(sample)
+	return Where(fmt.Sprintf("with data of type %v", t), func(e Logged) bool {
+		for _, d := range e.Data {
+			dt := reflect.TypeOf(d)
+			if dt == t || (dt != nil && dt.Kind() == reflect.Ptr && dt.Elem() == t) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func describeLevel(level string) string {
+	if level == "" {
+		return "log"
+	}
+	return level
+}
+
+func describe(predicates []Predicate) string {
+	descs := make([]string, len(predicates))
+	for i, p := range predicates {
+		descs[i] = p.description
+	}
+	return strings.Join(descs, ", ")
+}
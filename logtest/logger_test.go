@@ -0,0 +1,152 @@
+package logtest
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/TerrexTech/go-common-models/model"
+	"github.com/TerrexTech/go-logtransport/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// recordingTransport records the Records written to it.
+type recordingTransport struct {
+	records []*log.Record
+}
+
+func (t *recordingTransport) Write(r *log.Record) error {
+	t.records = append(t.records, r)
+	return nil
+}
+
+func (t *recordingTransport) Close() error {
+	return nil
+}
+
+var _ = Describe("Logger", func() {
+	var logger *Logger
+
+	BeforeEach(func() {
+		logger = New("testsvc")
+	})
+
+	It("should record entries with default ServiceName and Action", func() {
+		logger.SetAction("test-action")
+		logger.I(log.Entry{Description: "test-info"})
+		logger.E(log.Entry{
+			Action:      "other-action",
+			Description: "test-error",
+			ErrorCode:   4,
+			ServiceName: "othersvc",
+		}, &model.Event{})
+
+		entries := logger.Entries()
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0].Level).To(Equal(Info))
+		Expect(entries[0].Entry).To(Equal(log.Entry{
+			Action:      "test-action",
+			Description: "test-info",
+			ServiceName: "testsvc",
+		}))
+		Expect(entries[1].Level).To(Equal(Error))
+		Expect(entries[1].Entry.ServiceName).To(Equal("othersvc"))
+		Expect(entries[1].Data).To(Equal([]interface{}{&model.Event{}}))
+	})
+
+	It("should filter entries by level and predicates", func() {
+		logger.D(log.Entry{Action: "a", Description: "first"}, model.EventMeta{})
+		logger.E(log.Entry{Action: "a", ErrorCode: 4})
+		logger.E(log.Entry{Action: "b", ErrorCode: 4})
+		logger.F(log.Entry{Action: "b", ErrorCode: 5})
+
+		Expect(logger.Count(Error)).To(Equal(3))
+		Expect(logger.Count("", WithAction("a"))).To(Equal(2))
+		Expect(logger.Count(Error, WithAction("b"), WithErrorCode(4))).To(Equal(1))
+		Expect(logger.Count(Debug, WithData(model.EventMeta{}))).To(Equal(1))
+		Expect(logger.Count("", WithData(model.Event{}))).To(Equal(0))
+		Expect(logger.Count("", WithDescription("fir"))).To(Equal(1))
+		Expect(logger.Filter(Error, WithErrorCode(5))[0].Fatal).To(BeTrue())
+
+		logger.Reset()
+		Expect(logger.Entries()).To(BeEmpty())
+	})
+
+	It("should match entries using HaveLogged", func() {
+		logger.E(log.Entry{Action: "x", ErrorCode: 4}, &model.Event{})
+
+		Expect(logger).To(HaveLogged(Error, WithErrorCode(4), WithAction("x")))
+		Expect(logger).To(HaveLogged(Error, WithData(model.Event{})))
+		Expect(logger).ToNot(HaveLogged(Info))
+		Expect(logger).To(HaveLoggedTimes(1, ""))
+
+		matcher := HaveLogged(Error, WithErrorCode(5))
+		success, err := matcher.Match(logger)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(success).To(BeFalse())
+		Expect(matcher.FailureMessage(logger)).To(ContainSubstring(
+			"Expected to have logged ERROR entry with ErrorCode 5",
+		))
+		Expect(matcher.FailureMessage(logger)).To(ContainSubstring("ERROR: 4: testsvc: x"))
+
+		_, err = matcher.Match("not a logger")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should wait for entries logged asynchronously", func() {
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			logger.I(log.Entry{Action: "async"})
+		}()
+
+		e, err := logger.WaitFor(time.Second, Info, WithAction("async"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(e.Entry.Action).To(Equal("async"))
+
+		_, err = logger.WaitFor(10*time.Millisecond, Error)
+		Expect(err).To(HaveOccurred())
+
+		go logger.E(log.Entry{Action: "eventually"})
+		Eventually(logger).Should(HaveLogged(Error, WithAction("eventually")))
+	})
+
+	It("should build Records as log.Logger does", func() {
+		t := &recordingTransport{}
+		Expect(logger.AddTransport(t, log.TransportConfig{})).To(Succeed())
+		Expect(logger.SetRedaction(
+			log.RedactionRule{Key: "password"},
+			log.RedactionRule{Value: "secret"},
+		)).To(Succeed())
+		output := &bytes.Buffer{}
+		logger.SetOutput(output)
+
+		data := map[string]string{"password": "p4ss"}
+		logger.I(log.Entry{Description: "a secret"}, data)
+
+		Expect(t.records).To(HaveLen(1))
+		r := t.records[0]
+		Expect(r.Entry.Description).To(Equal("a [REDACTED]\n"))
+		Expect(r.Caller).To(ContainSubstring("logger_test.go:"))
+		Expect(r.Attachments).To(HaveLen(1))
+		Expect(r.Attachments[0].Redacted).To(BeTrue())
+		Expect(string(r.Attachments[0].Value)).ToNot(ContainSubstring("p4ss"))
+		Expect(output.String()).ToNot(ContainSubstring("secret"))
+
+		// Recorded entries are not masked
+		Expect(logger).To(HaveLogged(Info, WithDescription("a secret")))
+
+		err := logger.SetRedaction(log.RedactionRule{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should apply the array-threshold to Records", func() {
+		t := &recordingTransport{}
+		Expect(logger.AddTransport(t, log.TransportConfig{})).To(Succeed())
+		logger.SetArrayThreshold(2)
+
+		logger.D(log.Entry{Description: "array"}, []int{1, 2, 3, 4})
+		Expect(t.records).To(HaveLen(1))
+		Expect(t.records[0].Attachments[0].Truncated).To(BeTrue())
+		Expect(logger.Entries()[0].Data).To(Equal([]interface{}{[]int{1, 2, 3, 4}}))
+	})
+})
@@ -0,0 +1,13 @@
+package logtest
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestLogTest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "LogTest Suite")
+}
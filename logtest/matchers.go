@@ -0,0 +1,96 @@
+package logtest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/onsi/gomega/types"
+	"github.com/pkg/errors"
+)
+
+// HaveLogged succeeds if the recording Logger has an entry of level matching
+// all predicates. Entries of any level are matched if level is empty.
+// Use it with Eventually to wait for entries logged asynchronously:
+//
+//	Eventually(logger).Should(HaveLogged(logtest.Error, WithErrorCode(4)))
+func HaveLogged(level string, predicates ...Predicate) types.GomegaMatcher {
+	return &haveLoggedMatcher{
+		level:      level,
+		predicates: predicates,
+		times:      -1,
+	}
+}
+
+// HaveLoggedTimes succeeds if the recording Logger has exactly n entries of
+// level matching all predicates.
+func HaveLoggedTimes(n int, level string, predicates ...Predicate) types.GomegaMatcher {
+	return &haveLoggedMatcher{
+		level:      level,
+		predicates: predicates,
+		times:      n,
+	}
+}
+
+type haveLoggedMatcher struct {
+	level      string
+	predicates []Predicate
+	// times is the expected number of matching entries, or -1 for at least one.
+	times int
+
+	count int
+}
+
+func (m *haveLoggedMatcher) Match(actual interface{}) (bool, error) {
+	l, ok := actual.(*Logger)
+	if !ok {
+		return false, errors.Errorf(
+			"HaveLogged matcher expects a *logtest.Logger, got: %T", actual,
+		)
+	}
+	m.count = l.Count(m.level, m.predicates...)
+	if m.times < 0 {
+		return m.count > 0, nil
+	}
+	return m.count == m.times, nil
+}
+
+func (m *haveLoggedMatcher) expectation() string {
+	exp := fmt.Sprintf("%s entry", describeLevel(m.level))
+	if len(m.predicates) > 0 {
+		exp += " " + describe(m.predicates)
+	}
+	if m.times >= 0 {
+		exp = fmt.Sprintf("%d times %s, but found %d", m.times, exp, m.count)
+	}
+	return exp
+}
+
+func (m *haveLoggedMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf(
+		"Expected to have logged %s\nLogged entries:\n%s",
+		m.expectation(), formatEntries(actual),
+	)
+}
+
+func (m *haveLoggedMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf(
+		"Expected not to have logged %s\nLogged entries:\n%s",
+		m.expectation(), formatEntries(actual),
+	)
+}
+
+func formatEntries(actual interface{}) string {
+	l, ok := actual.(*Logger)
+	if !ok {
+		return ""
+	}
+	entries := l.Entries()
+	if len(entries) == 0 {
+		return "  <none>"
+	}
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = "  " + e.String()
+	}
+	return strings.Join(lines, "\n")
+}